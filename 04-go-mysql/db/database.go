@@ -1,89 +1,113 @@
-package db
-
-import (
-	"database/sql"
-	"fmt"
-
-	_ "github.com/go-sql-driver/mysql"
-)
-
-// Database connection URL
-const url = "username:password@tcp(localhost:3306)/goweb_db"
-
-// Variable to hold the database connection
-var db *sql.DB
-
-// Connect establishes a connection to the MySQL database
-func Connect() {
-	connection, err := sql.Open("mysql", url)
-	if err != nil {
-		panic(err)
-	}
-
-	fmt.Println("Connection done")
-	db = connection
-}
-
-// Close closes the database connection
-func Close() {
-	db.Close()
-}
-
-// Ping checks if the database connection is still alive
-func Ping() {
-	if err := db.Ping(); err != nil {
-		panic(err)
-	}
-}
-
-// CreateTable creates a new table if it doesn't already exist
-func CreateTable(schema string, name string) {
-	if !ExistsTable(name) {
-		_, err := Exec(schema) // Executes the schema to create the table
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		fmt.Printf("Table created %s", name)
-	} else {
-		fmt.Printf("Table %s already exists", name)
-	}
-}
-
-// ExistsTable checks if a table with the given name exists in the database
-func ExistsTable(tableName string) bool {
-	sql := fmt.Sprintf("SHOW TABLES LIKE '%s'", tableName)
-	rows, err := Query(sql)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return rows.Next()
-}
-
-// Exec is a helper function to execute SQL statements with arguments, if provided
-func Exec(query string, args ...interface{}) (sql.Result, error) {
-	result, err := db.Exec(query, args...)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return result, err
-}
-
-// Query is a helper function to execute SQL queries with arguments, if provided
-func Query(query string, args ...interface{}) (*sql.Rows, error) {
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return rows, err
-}
-
-// TruncateTable removes all data from the specified table
-func TruncateTable(tableName string) {
-	sql := fmt.Sprintf("TRUNCATE %s", tableName)
-	Exec(sql)
-	fmt.Printf("Table %s truncate", tableName)
-}
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Variable to hold the database connection
+var db *sql.DB
+
+// Connect establishes a connection to the MySQL database described by dsn,
+// e.g. "user:password@tcp(localhost:3306)/goweb_db"
+func Connect(dsn string) {
+	connection, err := sql.Open("mysql", dsn)
+	if err != nil {
+		panic(err)
+	}
+
+	db = connection
+}
+
+// Close closes the database connection
+func Close() {
+	db.Close()
+}
+
+// Ping checks if the database connection is still alive
+func Ping() {
+	if err := db.Ping(); err != nil {
+		panic(err)
+	}
+}
+
+// identifier matches the unquoted names MySQL accepts for tables, limited to
+// ASCII letters, digits, "_" and "$", with at most 64 characters
+var identifier = regexp.MustCompile(`^[A-Za-z0-9_$]{1,64}$`)
+
+// ErrInvalidIdentifier is returned for table names that are not plain identifiers
+var ErrInvalidIdentifier = errors.New("db: invalid identifier")
+
+// QuoteIdentifier checks that name is a plain identifier and quotes it with
+// backticks, so it can be put in a statement where parameters are not allowed
+func QuoteIdentifier(name string) (string, error) {
+	// Names made only of digits would be read as numbers
+	if !identifier.MatchString(name) || strings.Trim(name, "0123456789") == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+
+	return "`" + name + "`", nil
+}
+
+// ExistsTable checks if a table with the given name exists in the current database
+func ExistsTable(tableName string) (bool, error) {
+	if _, err := QuoteIdentifier(tableName); err != nil {
+		return false, err
+	}
+
+	// The name is a bound parameter, compared as is (LIKE would treat "_" and "%" as wildcards)
+	rows, err := Query("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", tableName)
+	if err != nil {
+		return false, err
+	}
+	// Give the connection back to the pool
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return false, err
+		}
+	}
+
+	return count > 0, rows.Err()
+}
+
+// Exec is a helper function to execute SQL statements with arguments, if provided
+func Exec(query string, args ...interface{}) (sql.Result, error) {
+	return ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is like Exec, but gives up when ctx is canceled or its deadline passes.
+// Errors are returned as is, for the caller to report.
+func ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(ctx, query, args...)
+}
+
+// Query is a helper function to execute SQL queries with arguments, if provided
+func Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is like Query, but gives up when ctx is canceled or its deadline passes.
+// Errors are returned as is, for the caller to report.
+func QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(ctx, query, args...)
+}
+
+// TruncateTable removes all data from the specified table
+func TruncateTable(tableName string) error {
+	table, err := QuoteIdentifier(tableName)
+	if err != nil {
+		return err
+	}
+
+	_, err = Exec("TRUNCATE TABLE " + table)
+	return err
+}
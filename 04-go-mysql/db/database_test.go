@@ -0,0 +1,67 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// hostileNames are table names trying to get out of the statement they are put in
+var hostileNames = []string{
+	"users; DROP TABLE users",
+	"users` ; DROP TABLE users; -- ",
+	"' OR '1'='1",
+	"users/**/",
+	"users\x00",
+	"%",
+	"",
+	"123",
+	"usérs",
+}
+
+// TestQuoteIdentifier checks that plain names are quoted and hostile ones rejected.
+func TestQuoteIdentifier(t *testing.T) {
+	// Define a table of test cases with a name and the expected quoted name
+	table := []struct {
+		name, quoted string
+	}{
+		{"users", "`users`"},
+		{"user_roles", "`user_roles`"},
+		{"$tmp", "`$tmp`"},
+		{"users2", "`users2`"},
+	}
+	for _, name := range hostileNames {
+		table = append(table, struct{ name, quoted string }{name, ""})
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		quoted, err := QuoteIdentifier(item.name)
+		if quoted != item.quoted || (err == nil) != (item.quoted != "") || (err != nil && !errors.Is(err, ErrInvalidIdentifier)) {
+			t.Errorf("Incorrect quoting of %q, got %q %v, expected %q", item.name, quoted, err, item.quoted)
+		}
+	}
+}
+
+// FuzzQuoteIdentifier checks that no table name, however hostile, can get out of its quotes.
+func FuzzQuoteIdentifier(f *testing.F) {
+	for _, name := range append(hostileNames, "users", "user_roles", "_", "$tmp") {
+		f.Add(name)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted, err := QuoteIdentifier(name)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidIdentifier) {
+				t.Errorf("Incorrect error for %q, got %v, expected %v", name, err, ErrInvalidIdentifier)
+			}
+			return
+		}
+
+		// The name is kept as is between backticks, without anything able to end
+		// the quotes, the statement or start a comment
+		if quoted != "`"+name+"`" || strings.ContainsAny(name, "`'\";\\ \t\r\n-/*#\x00") {
+			t.Errorf("Unsafe quoting of %q: %s", name, quoted)
+		}
+	})
+}
@@ -1,29 +1,70 @@
-package main
-
-import (
-	"fmt"
-	"gomysql/db"
-	"gomysql/models"
-)
-
-func main() {
-	db.Connect()
-	//fmt.Println(db.ExistsTable("users"))
-	//db.CreateTable(models.UserSchema, "users")
-	//db.Ping()
-	//db.TruncateTable("users")
-	user := models.CreateUser("user1", "user123465", "user@email.com")
-	// users := models.ListUsers()
-	// fmt.Println(users)
-
-	//user := models.GetUser(2)
-	fmt.Println(user)
-	// user.Username = "juan"
-	// user.Password = "jun123"
-	// user.Email = "juan@juan.com"
-	// user.Save()
-	//user.Delete()
-	//db.TruncateTable("users")
-	fmt.Println(models.ListUsers())
-	db.Close()
-}
+package main
+
+import (
+	"config"
+	"context"
+	"flag"
+	"fmt"
+	"gomysql/db"
+	"gomysql/migrations"
+	"gomysql/models"
+	"log"
+	"migrate"
+	"os"
+)
+
+func main() {
+	// Load the database settings from the defaults, the -config file,
+	// the environment (DB_USER, DB_PASSWORD, ...) and the command line
+	cfg := config.Default()
+	cfg.Database.Params = "parseTime=true" // Read the timestamps as time.Time
+	if err := config.Load(&cfg, flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+
+	// "migrate up|down|status|create NAME" manages the schema of the database and exits
+	if flag.Arg(0) == "migrate" {
+		if err := migrate.Command(context.Background(), cfg.Database, migrations.Files, migrations.Dir, migrations.Table, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := cfg.Database.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	db.Connect(cfg.Database.DataSourceName())
+	// Give up on the queries below if the database does not answer in time
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.QueryTimeout)
+	defer cancel()
+	//fmt.Println(db.ExistsTable("users"))
+	// The users table is created by "go run . migrate up"
+	//db.Ping()
+	//db.TruncateTable("users")
+	user, err := models.CreateUser(ctx, "user1", "user123465", "user@email.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// users, err := models.ListUsers(ctx)
+	// fmt.Println(users, err)
+
+	//user, err := models.GetUser(ctx, 2)
+	//if errors.Is(err, models.ErrNotFound) {
+	//	fmt.Println("There is no user with Id 2")
+	//}
+	fmt.Println(user)
+	// user.Username = "juan"
+	// user.Password = "jun123"
+	// user.Email = "juan@juan.com"
+	// err = user.Save(ctx)
+	//err = user.Delete(ctx) // Soft delete, user.Restore(ctx) brings it back and user.Purge(ctx) removes it
+
+	//db.TruncateTable("users")
+	users, err := models.ListUsers(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(users)
+	db.Close()
+}
@@ -0,0 +1,7 @@
+-- Users of the examples, formerly models.UserSchema
+CREATE TABLE IF NOT EXISTS users (
+	id INT(6) UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	email VARCHAR(50),
+	create_data TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost is the bcrypt cost used to hash new passwords.
+// Hashes created with a lower cost are upgraded the next time the user logs in.
+const PasswordCost = bcrypt.DefaultCost
+
+// isHashed reports whether the password already holds a bcrypt hash
+func isHashed(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+// HashPassword replaces the plaintext password of the user with its bcrypt hash.
+// Only the hash read from the database is left untouched, so it is safe to
+// call it every time the user is saved. Any other value is hashed, even one
+// shaped like a bcrypt hash: callers cannot choose the hash stored for a user.
+func (user *User) HashPassword() error {
+	if user.Password == user.storedPassword && isHashed(user.Password) {
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), PasswordCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hash)
+	user.storedPassword = user.Password
+	return nil
+}
+
+// MarkPasswordStored records that the password of the user was read from the
+// database, so HashPassword does not hash it again. scan calls it for every
+// user it reads.
+func (user *User) MarkPasswordStored() {
+	user.storedPassword = user.Password
+}
+
+// VerifyPassword reports whether the plaintext password matches the stored one.
+// Rows created before passwords were hashed still hold plaintext, those are
+// compared in constant time and reported by NeedsRehash.
+func (user *User) VerifyPassword(password string) bool {
+	if !isHashed(user.Password) {
+		return subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+// NeedsRehash reports whether the stored password is plaintext or was hashed
+// with a lower cost than PasswordCost. After a successful VerifyPassword the
+// caller should store the plaintext again so it gets hashed with the current cost.
+func (user *User) NeedsRehash() bool {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	return err != nil || cost < PasswordCost
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestHashPassword checks that only the hash read from the database is kept as is.
+func TestHashPassword(t *testing.T) {
+	chosen, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A hash given by the caller is hashed like any other password
+	user := NewUser("alex", string(chosen), "alex@example.com")
+	if err := user.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if user.Password == string(chosen) || !user.VerifyPassword(string(chosen)) {
+		t.Errorf("Incorrect password, the hash given was kept as is")
+	}
+
+	// Saving twice does not hash the hash
+	hashed := user.Password
+	if err := user.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != hashed {
+		t.Errorf("Incorrect password, got %s, expected the hash %s", user.Password, hashed)
+	}
+
+	// The hash read from the database is kept
+	stored := User{Password: string(chosen)}
+	stored.MarkPasswordStored()
+	if err := stored.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != string(chosen) {
+		t.Errorf("Incorrect password, got %s, expected the stored hash %s", stored.Password, chosen)
+	}
+}
@@ -1,93 +1,266 @@
-package models
-
-import (
-	"fmt"
-	"gomysql/db"
-)
-
-// User struct represents a user in the database
-type User struct {
-	Id       int64
-	Username string
-	Password string
-	Email    string
-}
-
-// Users type represents a list of User
-type Users []User
-
-// UserSchema defines the SQL statement to create the "users" table
-const UserSchema = `CREATE TABLE users (
-	id INT(6) UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-	username VARCHAR(30) NOT NULL,
-	password VARCHAR(100) NOT NULL,
-	email VARCHAR(50),
-	create_data TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`
-
-// NewUser creates and returns a new User instance with the provided details
-func NewUser(username, password, email string) *User {
-	user := &User{Username: username, Password: password, Email: email}
-	return user
-}
-
-// Private method to insert a new user into the database
-func (user *User) insert() {
-	sql := "INSERT users SET username=?, password=?, email=?"
-	result, _ := db.Exec(sql, user.Username, user.Password, user.Email)
-	user.Id, _ = result.LastInsertId()
-}
-
-// CreateUser creates a new user, saves it in the database, and returns it
-func CreateUser(username, password, email string) *User {
-	user := NewUser(username, password, email)
-	user.Save()
-	return user
-}
-
-// ListUsers retrieves and returns all users from the database
-func ListUsers() Users {
-	sql := "SELECT id, username, password, email FROM users"
-	users := Users{}
-	rows, _ := db.Query(sql)
-	for rows.Next() {
-		user := User{}
-		rows.Scan(&user.Id, &user.Username, &user.Password, &user.Email)
-		users = append(users, user)
-	}
-
-	return users
-}
-
-// GetUser retrieves a single user by ID from the database
-func GetUser(id int) *User {
-	user := NewUser("", "", "")
-	sql := "SELECT id, username, password, email FROM users WHERE id=?"
-	rows, _ := db.Query(sql, id)
-	for rows.Next() {
-		rows.Scan(&user.Id, &user.Username, &user.Password, &user.Email)
-	}
-
-	return user
-}
-
-// update modifies an existing user in the database
-func (user *User) update() {
-	sql := "UPDATE users SET username=?, password=?, email=? WHERE id=?"
-	db.Exec(sql, user.Username, user.Password, user.Email, user.Id)
-}
-
-// Save checks if a user already exists (by ID) and either inserts or updates it in the database
-func (user *User) Save() {
-	if user.Id == 0 {
-		user.insert()
-	} else {
-		user.update()
-	}
-}
-
-// Delete removes a user from the database by ID and prints a confirmation
-func (user *User) Delete() {
-	sql := "DELETE FROM users WHERE id=?"
-	db.Exec(sql, user.Id)
-	fmt.Printf("User with Id: %d deleted\n", user.Id)
-}
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gomysql/db"
+	"time"
+)
+
+// User struct represents a user in the database
+type User struct {
+	Id       int64
+	Username string
+	Password string // Bcrypt hash of the password
+	Email    string
+
+	storedPassword string // Password as read from the database, see HashPassword
+
+	// Set when the user is saved or deleted
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time // nil until the user is deleted
+}
+
+// userColumns are the columns read by every query, in the order expected by scan
+const userColumns = "id, username, password, email, created_at, updated_at, deleted_at"
+
+// Users type represents a list of User
+type Users []User
+
+// Errors returned by the functions of this package, compare them with errors.Is
+var (
+	ErrNotFound           = errors.New("models: user not found")
+	ErrInvalidCredentials = errors.New("models: invalid username or password")
+)
+
+// NewUser creates and returns a new User instance with the provided details
+func NewUser(username, password, email string) *User {
+	user := &User{Username: username, Password: password, Email: email}
+	return user
+}
+
+// Private method to insert a new user into the database
+func (user *User) insert(ctx context.Context) error {
+	// Never store a plaintext password
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+
+	// Set the timestamps like the database would, so the user does not need to be read again
+	user.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	user.UpdatedAt = user.CreatedAt
+
+	sql := "INSERT users SET username=?, password=?, email=?, created_at=?, updated_at=?"
+	result, err := db.ExecContext(ctx, sql, user.Username, user.Password, user.Email, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	user.Id, err = result.LastInsertId()
+	return err
+}
+
+// CreateUser creates a new user, saves it in the database, and returns it.
+// Like every function below, it stops waiting for the database when ctx is canceled.
+func CreateUser(ctx context.Context, username, password, email string) (*User, error) {
+	user := NewUser(username, password, email)
+	if err := user.Save(ctx); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ListUsers retrieves and returns all users from the database, except the deleted ones
+func ListUsers(ctx context.Context) (Users, error) {
+	return listUsersWhere(ctx, "deleted_at IS NULL")
+}
+
+// ListDeletedUsers retrieves and returns the deleted users, the ones that can be restored
+func ListDeletedUsers(ctx context.Context) (Users, error) {
+	return listUsersWhere(ctx, "deleted_at IS NOT NULL")
+}
+
+// listUsersWhere retrieves every user matching the condition
+func listUsersWhere(ctx context.Context, condition string, args ...interface{}) (Users, error) {
+	sql := "SELECT " + userColumns + " FROM users WHERE " + condition
+	rows, err := db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	// Give the connection back once every row is read
+	defer rows.Close()
+
+	users := Users{}
+	for rows.Next() {
+		user := User{}
+		if err := user.scan(rows); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	// Report an error that stopped the iteration early
+	return users, rows.Err()
+}
+
+// GetUser retrieves a single user by ID from the database, or ErrNotFound when it does not exist or is deleted
+func GetUser(ctx context.Context, id int) (*User, error) {
+	return getUserWhere(ctx, "id=? AND deleted_at IS NULL", id)
+}
+
+// GetUserByUsername retrieves a single user by username from the database, or ErrNotFound
+// when it does not exist or is deleted
+func GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return getUserWhere(ctx, "username=? AND deleted_at IS NULL", username)
+}
+
+// getUserWhere retrieves the first user matching the condition, or ErrNotFound
+func getUserWhere(ctx context.Context, condition string, args ...interface{}) (*User, error) {
+	sql := "SELECT " + userColumns + " FROM users WHERE " + condition
+	rows, err := db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+
+	user := NewUser("", "", "")
+	if err := user.scan(rows); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// scan reads the columns listed in userColumns from the current row into the user
+func (user *User) scan(rows *sql.Rows) error {
+	var deletedAt sql.NullTime
+	if err := rows.Scan(&user.Id, &user.Username, &user.Password, &user.Email, &user.CreatedAt, &user.UpdatedAt, &deletedAt); err != nil {
+		return err
+	}
+
+	user.DeletedAt = nil
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	user.MarkPasswordStored()
+
+	return nil
+}
+
+// Authenticate checks the username and password of a user and returns the user when they match,
+// or ErrInvalidCredentials when they do not, without telling which one was wrong.
+// If the stored password is still plaintext or was hashed with a lower cost,
+// it is hashed again with the current cost, upgrading the row transparently.
+func Authenticate(ctx context.Context, username, password string) (*User, error) {
+	user, err := GetUserByUsername(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.VerifyPassword(password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.NeedsRehash() {
+		user.Password = password
+		if err := user.Save(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// update modifies an existing user in the database, or returns ErrNotFound
+func (user *User) update(ctx context.Context) error {
+	// Never store a plaintext password
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+
+	user.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	sql := "UPDATE users SET username=?, password=?, email=?, updated_at=? WHERE id=? AND deleted_at IS NULL"
+	result, err := db.ExecContext(ctx, sql, user.Username, user.Password, user.Email, user.UpdatedAt, user.Id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	// MySQL does not count rows saved with the same values, so make sure the user exists
+	_, err = GetUser(ctx, int(user.Id))
+	return err
+}
+
+// Save checks if a user already exists (by ID) and either inserts or updates it in the database.
+// Updating a user whose ID is not in the database returns ErrNotFound.
+func (user *User) Save(ctx context.Context) error {
+	if user.Id == 0 {
+		return user.insert(ctx)
+	}
+
+	return user.update(ctx)
+}
+
+// Delete soft deletes a user by ID: it is kept in the database but left out of
+// ListUsers and GetUser until Restore is called. It returns ErrNotFound when
+// there is no such user or it is already deleted.
+func (user *User) Delete(ctx context.Context) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := change(ctx, "UPDATE users SET deleted_at=? WHERE id=? AND deleted_at IS NULL", now, user.Id); err != nil {
+		return err
+	}
+
+	user.DeletedAt = &now
+	return nil
+}
+
+// Restore brings back a deleted user by ID, or returns ErrNotFound
+func (user *User) Restore(ctx context.Context) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := change(ctx, "UPDATE users SET deleted_at=NULL, updated_at=? WHERE id=? AND deleted_at IS NOT NULL", now, user.Id); err != nil {
+		return err
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = now
+	return nil
+}
+
+// Purge removes a user from the database for good by ID, deleted or not, or returns ErrNotFound
+func (user *User) Purge(ctx context.Context) error {
+	return change(ctx, "DELETE FROM users WHERE id=?", user.Id)
+}
+
+// change runs a statement modifying a single user, returning ErrNotFound when no row matched
+func change(ctx context.Context, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -1,92 +1,248 @@
-package db
-
-import (
-	"database/sql"
-	"fmt"
-
-	_ "github.com/go-sql-driver/mysql"
-)
-
-// Database connection URL
-const url = "your_user:your_password@tcp(localhost:3306)/goweb_db"
-
-// Variable to hold the database connection
-var db *sql.DB
-
-// Connect establishes a connection to the MySQL database
-func Connect() {
-	connection, err := sql.Open("mysql", url)
-	if err != nil {
-		panic(err)
-	}
-
-	fmt.Println("Connection done")
-	db = connection
-}
-
-// Close closes the database connection
-func Close() {
-	db.Close()
-}
-
-// Ping checks if the database connection is still alive
-func Ping() {
-	if err := db.Ping(); err != nil {
-		panic(err)
-	}
-}
-
-// CreateTable creates a new table if it doesn't already exist
-func CreateTable(schema string, name string) {
-	if !ExistsTable(name) {
-		_, err := Exec(schema) // Executes the schema to create the table
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		fmt.Printf("Table created %s", name)
-	} else {
-		fmt.Printf("Table %s already exists", name)
-	}
-}
-
-// ExistsTable checks if a table with the given name exists in the database
-func ExistsTable(tableName string) bool {
-	sql := fmt.Sprintf("SHOW TABLES LIKE '%s'", tableName)
-	rows, err := Query(sql)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return rows.Next()
-}
-
-// Exec is a helper function to execute SQL statements with arguments, if provided
-func Exec(query string, args ...interface{}) (sql.Result, error) {
-	Connect()
-	result, err := db.Exec(query, args...)
-	Close()
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return result, err
-}
-
-// Query is a helper function to execute SQL queries with arguments, if provided
-func Query(query string, args ...interface{}) (*sql.Rows, error) {
-	Connect()
-	rows, err := db.Query(query, args...)
-	Close()
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	return rows, err
-}
-
-// TruncateTable removes all data from the specified table
-func TruncateTable(tableName string) {
-	sql := fmt.Sprintf("TRUNCATE %s", tableName)
-	Exec(sql)
-}
+package db
+
+import (
+	"config"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"logging"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// ErrNotConnected is returned by Exec and Query when Open was not called
+var ErrNotConnected = errors.New("db: not connected, call Open first")
+
+// Config holds the settings of the connection pool
+type Config struct {
+	Driver          string        // Name of the database/sql driver
+	DSN             string        // Data source name passed to the driver
+	MaxOpenConns    int           // Maximum number of open connections, 0 means unlimited
+	MaxIdleConns    int           // Maximum number of idle connections kept in the pool
+	ConnMaxLifetime time.Duration // Connections are replaced after this time, 0 means never
+	ConnMaxIdleTime time.Duration // Idle connections are closed after this time, 0 means never
+	ConnectRetries  int           // Number of extra pings on startup before giving up
+	RetryDelay      time.Duration // Time to wait between two startup pings
+}
+
+// ConfigFrom returns the Config of a MySQL pool with the database settings
+// loaded by the config package
+func ConfigFrom(cfg config.Database) Config {
+	return Config{
+		Driver:          "mysql",
+		DSN:             cfg.DataSourceName(),
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		ConnectRetries:  cfg.ConnectRetries,
+		RetryDelay:      cfg.RetryDelay,
+	}
+}
+
+// Variable to hold the connection pool, shared by every statement
+var (
+	db *sql.DB
+	mu sync.RWMutex // Guards db while it is opened or closed
+)
+
+// Open creates the connection pool described by cfg and pings the database,
+// retrying while it is not reachable yet (e.g. when both start together).
+// The pool is kept open until Close is called.
+func Open(cfg Config) error {
+	connection, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return err
+	}
+
+	// Size the pool and recycle old connections
+	connection.SetMaxOpenConns(cfg.MaxOpenConns)
+	connection.SetMaxIdleConns(cfg.MaxIdleConns)
+	connection.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	connection.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	// Wait for the database to accept connections
+	for attempt := 0; ; attempt++ {
+		if err = connection.Ping(); err == nil {
+			break
+		}
+		if attempt >= cfg.ConnectRetries {
+			connection.Close()
+			return fmt.Errorf("db: cannot reach the database after %d attempts: %w", attempt+1, err)
+		}
+		slog.Warn("Database not ready, retrying", "err", err, "delay", cfg.RetryDelay.String())
+		time.Sleep(cfg.RetryDelay)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if db != nil {
+		db.Close()
+	}
+	db = connection
+
+	slog.Info("Connection done")
+	return nil
+}
+
+// Close closes the connection pool, waiting for running statements to finish.
+// It is safe to call it more than once.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	err := db.Close()
+	db = nil
+	return err
+}
+
+// Ping checks if the database connection is still alive
+func Ping() error {
+	return PingContext(context.Background())
+}
+
+// PingContext checks if the database answers before the deadline of ctx,
+// e.g. for the readiness probe
+func PingContext(ctx context.Context) error {
+	pool, err := conn()
+	if err != nil {
+		return err
+	}
+
+	return pool.PingContext(ctx)
+}
+
+// Stats returns the statistics of the connection pool
+func Stats() sql.DBStats {
+	pool, err := conn()
+	if err != nil {
+		return sql.DBStats{}
+	}
+
+	return pool.Stats()
+}
+
+// conn returns the connection pool, or ErrNotConnected before Open
+func conn() (*sql.DB, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if db == nil {
+		return nil, ErrNotConnected
+	}
+
+	return db, nil
+}
+
+// identifier matches the unquoted names MySQL accepts for tables, limited to
+// ASCII letters, digits, "_" and "$", with at most 64 characters
+var identifier = regexp.MustCompile(`^[A-Za-z0-9_$]{1,64}$`)
+
+// ErrInvalidIdentifier is returned for table names that are not plain identifiers
+var ErrInvalidIdentifier = errors.New("db: invalid identifier")
+
+// QuoteIdentifier checks that name is a plain identifier and quotes it with
+// backticks, so it can be put in a statement where parameters are not allowed
+func QuoteIdentifier(name string) (string, error) {
+	// Names made only of digits would be read as numbers
+	if !identifier.MatchString(name) || strings.Trim(name, "0123456789") == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+
+	return "`" + name + "`", nil
+}
+
+// ExistsTable checks if a table with the given name exists in the current database
+func ExistsTable(tableName string) (bool, error) {
+	if _, err := QuoteIdentifier(tableName); err != nil {
+		return false, err
+	}
+
+	// The name is a bound parameter, compared as is (LIKE would treat "_" and "%" as wildcards)
+	rows, err := Query("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", tableName)
+	if err != nil {
+		return false, err
+	}
+	// Give the connection back to the pool
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return false, err
+		}
+	}
+
+	return count > 0, rows.Err()
+}
+
+// Exec is a helper function to execute SQL statements with arguments, if provided
+func Exec(query string, args ...interface{}) (sql.Result, error) {
+	return ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is like Exec, but gives up when ctx is canceled or its deadline passes
+func ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	pool, err := conn()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := pool.ExecContext(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Error("SQL statement failed", "query", query, "err", err)
+	}
+
+	return result, err
+}
+
+// BeginTx starts a transaction on a connection of the pool. The caller must
+// Commit or Rollback it to give the connection back to the pool.
+func BeginTx(ctx context.Context) (*sql.Tx, error) {
+	pool, err := conn()
+	if err != nil {
+		return nil, err
+	}
+
+	return pool.BeginTx(ctx, nil)
+}
+
+// Query is a helper function to execute SQL queries with arguments, if provided.
+// The caller must close the returned rows to give the connection back to the pool.
+func Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is like Query, but gives up when ctx is canceled or its deadline passes
+func QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	pool, err := conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.QueryContext(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Error("SQL query failed", "query", query, "err", err)
+	}
+
+	return rows, err
+}
+
+// TruncateTable removes all data from the specified table
+func TruncateTable(tableName string) error {
+	table, err := QuoteIdentifier(tableName)
+	if err != nil {
+		return err
+	}
+
+	_, err = Exec("TRUNCATE TABLE " + table)
+	return err
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"config"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver, so the tests do not need a MySQL server
+)
+
+// openTestDB opens the pool on a fresh SQLite database with a few users
+// and returns its configuration. The pool is closed when the test ends.
+func openTestDB(tb testing.TB) Config {
+	cfg := ConfigFrom(config.Default().Database)
+	cfg.Driver = "sqlite"
+	cfg.DSN = filepath.Join(tb.TempDir(), "test.db")
+	cfg.ConnectRetries = 0
+
+	if err := Open(cfg); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { Close() })
+
+	// Create the table and insert the users the tests read back
+	if _, err := Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT)"); err != nil {
+		tb.Fatal(err)
+	}
+	for _, username := range []string{"alex", "kim", "sam"} {
+		if _, err := Exec("INSERT INTO users (username) VALUES (?)", username); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return cfg
+}
+
+// countUsers reads every row returned by the query and returns how many there were
+func countUsers(rows *sql.Rows) int {
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var id int64
+		var username string
+		rows.Scan(&id, &username)
+		count++
+	}
+
+	return count
+}
+
+// TestQuery checks that the rows returned by Query can still be read by the caller.
+func TestQuery(t *testing.T) {
+	openTestDB(t)
+
+	rows, err := Query("SELECT id, username FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countUsers(rows); got != 3 {
+		t.Errorf("Incorrect number of rows, got %d, expected %d", got, 3)
+	}
+
+	// Every connection must be back in the pool once the rows are closed
+	if inUse := Stats().InUse; inUse != 0 {
+		t.Errorf("Incorrect number of connections in use, got %d, expected %d", inUse, 0)
+	}
+}
+
+// TestClose checks that statements fail cleanly once the pool is closed.
+func TestClose(t *testing.T) {
+	openTestDB(t)
+
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing twice is allowed
+	if err := Close(); err != nil {
+		t.Errorf("Incorrect error closing twice, got %v, expected nil", err)
+	}
+	if _, err := Query("SELECT id, username FROM users"); err != ErrNotConnected {
+		t.Errorf("Incorrect error after Close, got %v, expected %v", err, ErrNotConnected)
+	}
+}
+
+// hostileNames are table names trying to get out of the statement they are put in
+var hostileNames = []string{
+	"users; DROP TABLE users",
+	"users` ; DROP TABLE users; -- ",
+	"' OR '1'='1",
+	"users/**/",
+	"users\x00",
+	"%",
+	"",
+	"123",
+	"usérs",
+}
+
+// TestTruncateTable checks that hostile names are rejected before reaching the database.
+func TestTruncateTable(t *testing.T) {
+	openTestDB(t)
+
+	for _, name := range hostileNames {
+		if err := TruncateTable(name); !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("Incorrect error for %q, got %v, expected %v", name, err, ErrInvalidIdentifier)
+		}
+	}
+
+	// Nothing was dropped or truncated
+	rows, err := Query("SELECT id, username FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countUsers(rows); got != 3 {
+		t.Errorf("Incorrect number of rows, got %d, expected %d", got, 3)
+	}
+}
+
+// FuzzQuoteIdentifier checks that no table name, however hostile, can get out of its quotes.
+func FuzzQuoteIdentifier(f *testing.F) {
+	for _, name := range append(hostileNames, "users", "user_roles", "_", "$tmp") {
+		f.Add(name)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted, err := QuoteIdentifier(name)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidIdentifier) {
+				t.Errorf("Incorrect error for %q, got %v, expected %v", name, err, ErrInvalidIdentifier)
+			}
+			return
+		}
+
+		// The name is kept as is between backticks, without anything able to end
+		// the quotes, the statement or start a comment
+		if quoted != "`"+name+"`" || strings.ContainsAny(name, "`'\";\\ \t\r\n-/*#\x00") {
+			t.Errorf("Unsafe quoting of %q: %s", name, quoted)
+		}
+	})
+}
+
+// BenchmarkQueryPool measures a query through the long-lived connection pool.
+func BenchmarkQueryPool(b *testing.B) {
+	openTestDB(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := Query("SELECT id, username FROM users")
+		if err != nil {
+			b.Fatal(err)
+		}
+		countUsers(rows)
+	}
+}
+
+// BenchmarkQueryReconnect measures the same query opening and closing a
+// connection around every statement, as the package used to do.
+func BenchmarkQueryReconnect(b *testing.B) {
+	cfg := openTestDB(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		connection, err := sql.Open(cfg.Driver, cfg.DSN)
+		if err != nil {
+			b.Fatal(err)
+		}
+		rows, err := connection.Query("SELECT id, username FROM users")
+		if err != nil {
+			b.Fatal(err)
+		}
+		countUsers(rows)
+		connection.Close()
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"apirest/models"
+	"apperr"
+	"auth"
+	"context"
+	"errors"
+	"fmt"
+	"logging"
+	"mail"
+	"net/http"
+	"strconv"
+	"time"
+	"validation"
+
+	"github.com/gorilla/mux"
+)
+
+// Lifetimes of the tokens sent by email
+const (
+	VerifyEmailTTL   = 24 * time.Hour // Verifying an email address
+	ResetPasswordTTL = time.Hour      // Choosing a new password
+)
+
+// verifyEmailRequest holds the body of an email verification request
+type verifyEmailRequest struct {
+	Token string `json:"token"` // Token received by email, empty to ask for a new one
+}
+
+// forgotPasswordRequest holds the body of a forgotten password request
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=50"`
+}
+
+// resetPasswordRequest holds the body of a password reset request
+type resetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// AccountHandler groups the HTTP handlers verifying the email addresses of the
+// users and resetting their passwords. Both send a one-time token by email:
+// a token signed by the TokenManager whose ID is kept by the store.
+type AccountHandler struct {
+	store  models.UserStore   // Storage backend holding the users and their tokens
+	tokens *auth.TokenManager // Signs the tokens sent by email
+	mailer mail.Mailer        // Sends the emails
+}
+
+// NewAccountHandler creates an AccountHandler sending its emails with the given mailer
+func NewAccountHandler(store models.UserStore, tokens *auth.TokenManager, mailer mail.Mailer) *AccountHandler {
+	return &AccountHandler{store: store, tokens: tokens, mailer: mailer}
+}
+
+// SendVerification emails the user a token proving they own their address,
+// valid for VerifyEmailTTL. The tokens sent before are revoked.
+func (h *AccountHandler) SendVerification(ctx context.Context, user *models.User) error {
+	token, err := h.issue(ctx, user, auth.VerifyEmailToken, VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Confirm that %s is your email address by sending this token before %s:\n\n"+
+		"POST /api/user/%d/verify-email\n{\"token\": %q}\n\n"+
+		"If you did not sign up, ignore this email.\n",
+		user.Username, user.Email, time.Now().Add(VerifyEmailTTL).UTC().Format(time.RFC1123), user.Id, token)
+	return h.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Verify your email address", Body: body})
+}
+
+// sendReset emails the user a token letting them choose a new password,
+// valid for ResetPasswordTTL. The tokens sent before are revoked.
+func (h *AccountHandler) sendReset(ctx context.Context, user *models.User) error {
+	token, err := h.issue(ctx, user, auth.ResetPasswordToken, ResetPasswordTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Choose a new password by sending this token before %s:\n\n"+
+		"POST /api/password/reset\n{\"token\": %q, \"password\": \"<new password>\"}\n\n"+
+		"If you did not ask for it, ignore this email, your password is unchanged.\n",
+		user.Username, time.Now().Add(ResetPasswordTTL).UTC().Format(time.RFC1123), token)
+	return h.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Reset your password", Body: body})
+}
+
+// issue revokes the unused tokens of the user with the given purpose, then
+// stores a new one and returns it signed
+func (h *AccountHandler) issue(ctx context.Context, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	if err := h.store.RevokeTokens(ctx, user.Id, purpose); err != nil {
+		return "", err
+	}
+
+	token := models.NewToken(user, purpose, ttl)
+	signed, err := h.tokens.IssueOneTime(user.Id, purpose, token.Id, ttl)
+	if err != nil {
+		return "", apperr.Internal(err)
+	}
+	if err := h.store.SaveToken(ctx, token); err != nil {
+		return "", err
+	}
+
+	return signed, nil
+}
+
+// use checks the signature, kind and expiry of a token received by email, and
+// that it was issued for the user with the given ID unless it is 0, then marks
+// it as used in the store. It returns the stored token, or
+// models.ErrInvalidToken when the token cannot be used.
+func (h *AccountHandler) use(ctx context.Context, signed, purpose string, userId int64) (*models.Token, error) {
+	claims, err := h.tokens.Parse(signed, purpose)
+	if err != nil || claims.ID == "" || userId != 0 && claims.UserId() != userId {
+		// Tokens of other users are refused before they are used, so they still work for their owner.
+		return nil, models.ErrInvalidToken
+	}
+
+	token, err := h.store.UseToken(ctx, claims.ID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if token.UserId != claims.UserId() {
+		return nil, models.ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// VerifyEmail handles the request to verify the email address of a user by their ID.
+// With the token received by email in the body, it marks the address as
+// verified and sends the user. Without a token, the user themselves or an
+// admin gets a new token sent to the address, with a 202 response.
+func (h *AccountHandler) VerifyEmail(rw http.ResponseWriter, r *http.Request) {
+	// Decode the token from the request body, rejecting unknown fields.
+	body := verifyEmailRequest{}
+	if errs := validation.DecodeJSON(r.Body, &body); len(errs) > 0 {
+		// If the decoding fails, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+	userId, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if body.Token == "" {
+		h.resendVerification(rw, r, userId)
+		return
+	}
+
+	// Use the token, it must have been sent to the current address of the user of the route.
+	token, err := h.use(r.Context(), body.Token, auth.VerifyEmailToken, userId)
+	if err != nil {
+		models.SendError(rw, r, err)
+		return
+	}
+	user, err := h.store.GetUser(r.Context(), userId)
+	if err != nil {
+		models.SendError(rw, r, err)
+		return
+	}
+	if user.Email != token.Email {
+		// The address changed since the token was sent.
+		models.SendError(rw, r, models.ErrInvalidToken)
+		return
+	}
+
+	// Mark the address as verified, verifying it again keeps the first time.
+	if !user.IsEmailVerified() {
+		now := models.Now()
+		user.EmailVerifiedAt = &now
+		if err := h.store.SaveUser(r.Context(), user); err != nil {
+			models.SendError(rw, r, err)
+			return
+		}
+	}
+	sendUser(rw, *user)
+}
+
+// resendVerification sends a new verification token to the user with the
+// given ID, when the request comes from the user themselves or an admin
+func (h *AccountHandler) resendVerification(rw http.ResponseWriter, r *http.Request, userId int64) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		models.SendError(rw, r, apperr.Unauthorized("Send the token received by email, or authenticate to get a new one"))
+		return
+	}
+	if !identity.IsAdmin() && identity.Id != userId {
+		models.SendError(rw, r, apperr.Forbidden())
+		return
+	}
+
+	user, err := h.store.GetUser(r.Context(), userId)
+	if err != nil {
+		models.SendError(rw, r, err)
+		return
+	}
+	if user.IsEmailVerified() {
+		models.SendError(rw, r, apperr.Conflict("The email address is already verified"))
+		return
+	}
+	if err := h.SendVerification(r.Context(), user); err != nil {
+		models.SendError(rw, r, apperr.From(err))
+		return
+	}
+
+	models.SendMessage(rw, http.StatusAccepted, "A verification email was sent to "+user.Email)
+}
+
+// ForgotPassword handles the request to reset a forgotten password.
+// It emails a token to the active user with the given address, if there is
+// one. The response is the same either way, so it does not reveal which
+// addresses belong to users.
+func (h *AccountHandler) ForgotPassword(rw http.ResponseWriter, r *http.Request) {
+	// Decode the email address from the request body, rejecting unknown fields.
+	body := forgotPasswordRequest{}
+	errs := validation.DecodeJSON(r.Body, &body)
+	if len(errs) == 0 {
+		errs = validation.Struct(&body)
+	}
+	if len(errs) > 0 {
+		// If the body is not valid, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	user, err := h.store.GetUserByEmail(r.Context(), body.Email)
+	if err == nil {
+		err = h.sendReset(r.Context(), user)
+	}
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		// Only the logs tell the email could not be sent.
+		logging.FromContext(r.Context()).Error("password reset email failed", "err", err)
+	}
+
+	models.SendMessage(rw, http.StatusAccepted, "If the address belongs to a user, a password reset email was sent to it")
+}
+
+// ResetPassword handles the request to choose a new password with the token
+// received by email. The token also proves the user owns their address, so
+// it is marked as verified. It sends the user with their new version.
+func (h *AccountHandler) ResetPassword(rw http.ResponseWriter, r *http.Request) {
+	// Decode the token and the new password from the request body, rejecting unknown fields.
+	body := resetPasswordRequest{}
+	errs := validation.DecodeJSON(r.Body, &body)
+	if len(errs) == 0 {
+		errs = validation.Struct(&body)
+	}
+	if len(errs) > 0 {
+		// If the body is not valid, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	// Use the token, it must have been sent to the current address of its user.
+	token, err := h.use(r.Context(), body.Token, auth.ResetPasswordToken, 0)
+	if err != nil {
+		models.SendError(rw, r, err)
+		return
+	}
+	user, err := h.store.GetUser(r.Context(), token.UserId)
+	if errors.Is(err, models.ErrNotFound) || err == nil && user.Email != token.Email {
+		// The user was deleted or their address changed since the token was sent.
+		err = models.ErrInvalidToken
+	}
+	if err != nil {
+		models.SendError(rw, r, err)
+		return
+	}
+
+	// Save the new password, the store hashes it.
+	user.Password = body.Password
+	if !user.IsEmailVerified() {
+		now := models.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := h.store.SaveUser(r.Context(), user); err != nil {
+		models.SendError(rw, r, err)
+		return
+	}
+	sendUser(rw, *user)
+}
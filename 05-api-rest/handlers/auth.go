@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"apirest/models"
+	"apperr"
+	"auth"
+	"errors"
+	"logging"
+	"net/http"
+	"validation"
+)
+
+// credentials holds the body of a login request
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// refreshRequest holds the body of a token refresh request
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthHandler groups the HTTP handlers used to authenticate users
+type AuthHandler struct {
+	store  models.UserStore   // Storage backend holding the users
+	tokens *auth.TokenManager // Signs the issued tokens
+}
+
+// NewAuthHandler creates an AuthHandler checking credentials against the given store
+func NewAuthHandler(store models.UserStore, tokens *auth.TokenManager) *AuthHandler {
+	return &AuthHandler{store: store, tokens: tokens}
+}
+
+// Login handles the request to authenticate a user.
+// It checks the username and password against the users table and sends a
+// pair of access and refresh tokens when they match.
+func (h *AuthHandler) Login(rw http.ResponseWriter, r *http.Request) {
+	// Decode the credentials from the request body.
+	creds := credentials{}
+	if errs := validation.DecodeJSON(r.Body, &creds); len(errs) > 0 {
+		// If the decoding fails, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	// Look up the user and verify the password. Both failures get the same
+	// answer so the response does not reveal which usernames exist.
+	user, err := h.store.GetUserByUsername(r.Context(), creds.Username)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		// If the database fails, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+		return
+	}
+	if err != nil || !user.VerifyPassword(creds.Password) {
+		models.SendError(rw, r, apperr.Unauthorized("Invalid username or password"))
+		return
+	}
+
+	// Upgrade plaintext or outdated hashes now that we know the plaintext.
+	// The user is logged in either way, the hash is upgraded on a later login.
+	if user.NeedsRehash() {
+		user.Password = creds.Password
+		if err := h.store.SaveUser(r.Context(), user); err != nil {
+			logging.FromContext(r.Context()).Error("password rehash failed", "user_id", user.Id, "err", err)
+		}
+	}
+
+	h.sendTokens(rw, r, user)
+}
+
+// Refresh handles the request to exchange a refresh token for a new pair of tokens.
+func (h *AuthHandler) Refresh(rw http.ResponseWriter, r *http.Request) {
+	// Decode the refresh token from the request body.
+	body := refreshRequest{}
+	if errs := validation.DecodeJSON(r.Body, &body); len(errs) > 0 {
+		// If the decoding fails, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	// Verify the token, only refresh tokens are accepted here.
+	claims, err := h.tokens.Parse(body.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		models.SendError(rw, r, apperr.Unauthorized("Invalid or expired refresh token"))
+		return
+	}
+
+	// Make sure the user still exists before issuing new tokens.
+	user, err := h.store.GetUser(r.Context(), claims.UserId())
+	if err != nil {
+		models.SendError(rw, r, apperr.Unauthorized("Invalid or expired refresh token"))
+		return
+	}
+
+	h.sendTokens(rw, r, user)
+}
+
+// sendTokens issues a new pair of tokens for the user and sends it as the response.
+func (h *AuthHandler) sendTokens(rw http.ResponseWriter, r *http.Request, user *models.User) {
+	if tokens, err := h.tokens.Issue(user.Id, user.Username, user.Role); err != nil {
+		// If the tokens cannot be signed, send an "Internal Server Error" response.
+		models.SendError(rw, r, apperr.Internal(err))
+	} else {
+		// Otherwise, send the tokens as the response.
+		models.SendData(rw, tokens)
+	}
+}
@@ -1,117 +1,370 @@
-package handlers
-
-import (
-	"apirest/models"
-	"encoding/json"
-	"net/http"
-	"strconv"
-
-	"github.com/gorilla/mux"
-)
-
-// GetUsers handles the request to list all users.
-// If there is an error, it sends a "Not Found" response, otherwise sends the list of users.
-func GetUsers(rw http.ResponseWriter, r *http.Request) {
-	// Attempt to retrieve the list of users.
-	if users, err := models.ListUsers(); err != nil {
-		// If an error occurs, send a "Not Found" response.
-		models.SendNotFound(rw)
-	} else {
-		// Otherwise, send the list of users as the response.
-		models.SendData(rw, users)
-	}
-}
-
-// GetUser handles the request to fetch a single user by their ID.
-// If the user is found, it sends the user data, otherwise sends a "Not Found" response.
-func GetUser(rw http.ResponseWriter, r *http.Request) {
-	// Attempt to retrieve the user based on the request's ID.
-	if user, err := getUserByRequest(r); err != nil {
-		// If an error occurs, send a "Not Found" response.
-		models.SendNotFound(rw)
-	} else {
-		// Otherwise, send the user data as the response.
-		models.SendData(rw, user)
-	}
-}
-
-// CreateUser handles the request to create a new user.
-// It decodes the user data from the request body, saves the user to the database,
-// and sends the newly created user data as the response.
-func CreateUser(rw http.ResponseWriter, r *http.Request) {
-	// Create an empty User object to hold the incoming data.
-	user := models.User{}
-	// Decode the request body into the User object.
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&user); err != nil {
-		// If the decoding fails, send an "Unprocessable Entity" response.
-		models.SendUnprocessableEntity(rw)
-	} else {
-		// Save the new user to the database.
-		user.Save()
-		// Send the newly created user data as the response.
-		models.SendData(rw, user)
-	}
-}
-
-// DeleteUser handles the request to delete a user by their ID.
-// If the user is found, it deletes the user and sends the deleted user data as the response.
-func DeleteUser(rw http.ResponseWriter, r *http.Request) {
-	// Attempt to retrieve the user based on the request's ID.
-	if user, err := getUserByRequest(r); err != nil {
-		// If the user is not found, send a "Not Found" response.
-		models.SendNotFound(rw)
-	} else {
-		// Delete the user.
-		user.Delete()
-		// Send the deleted user data as the response.
-		models.SendData(rw, user)
-	}
-}
-
-// UpdateUser handles the request to update an existing user's data.
-// It retrieves the user based on the ID, decodes the new user data from the request body,
-// updates the user in the database, and sends the updated user data as the response.
-func UpdateUser(rw http.ResponseWriter, r *http.Request) {
-	var userId int64
-	// Attempt to retrieve the user based on the request's ID.
-	if user, err := getUserByRequest(r); err != nil {
-		// If the user is not found, send a "Not Found" response.
-		models.SendNotFound(rw)
-	} else {
-		// Store the user's ID for later use in the update.
-		userId = user.Id
-	}
-
-	// Create an empty User object to hold the updated data.
-	user := models.User{}
-	// Decode the new user data from the request body.
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&user); err != nil {
-		// If the decoding fails, send an "Unprocessable Entity" response.
-		models.SendUnprocessableEntity(rw)
-	} else {
-		// Set the user's ID to the value retrieved earlier (preserving the original ID).
-		user.Id = userId
-		// Save the updated user to the database.
-		user.Save()
-		// Send the updated user data as the response.
-		models.SendData(rw, user)
-	}
-}
-
-// getUserByRequest extracts the user ID from the request and retrieves the user from the database.
-// Returns the user and any error encountered during retrieval.
-func getUserByRequest(r *http.Request) (models.User, error) {
-	// Get the user ID from the request's URL parameters.
-	vars := mux.Vars(r)
-	userId, _ := strconv.Atoi(vars["id"])
-	// Retrieve the user from the database by ID.
-	if user, err := models.GetUser(userId); err != nil {
-		// If an error occurs, return the error.
-		return *user, err
-	} else {
-		// Otherwise, return the user and nil error.
-		return *user, nil
-	}
-}
+package handlers
+
+import (
+	"apirest/models"
+	"apperr"
+	"auth"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"logging"
+	"net/http"
+	"patch"
+	"strconv"
+	"time"
+	"validation"
+
+	"github.com/gorilla/mux"
+)
+
+// UserHandler groups the HTTP handlers of the user API.
+// It receives the storage backend through NewUserHandler, so the same
+// handlers can run on top of MySQL, SQLite or an in-memory store.
+type UserHandler struct {
+	store    models.UserStore // Storage backend used to persist users
+	accounts *AccountHandler  // Emails new users a token to verify their address
+}
+
+// NewUserHandler creates a UserHandler that keeps users in the given store and
+// asks new users to verify their email address through accounts
+func NewUserHandler(store models.UserStore, accounts *AccountHandler) *UserHandler {
+	return &UserHandler{store: store, accounts: accounts}
+}
+
+// GetUsers handles the request to list users.
+// It reads the pagination, sorting and filtering options from the query string
+// (?page=, ?per_page=, ?sort=, ?username=, ?email_like=, ...) and sends one page
+// of users together with the totals and the links to the next and previous pages.
+func (h *UserHandler) GetUsers(rw http.ResponseWriter, r *http.Request) {
+	// Parse the list options from the query string.
+	opts, err := models.ParseListOptions(r.URL.Query())
+	if err != nil {
+		// If the options are invalid, send a "Bad Request" response.
+		models.SendError(rw, r, apperr.BadRequest(err.Error()))
+		return
+	}
+
+	// Attempt to retrieve the requested page of users.
+	if users, total, err := h.store.ListUsers(r.Context(), opts); err != nil {
+		// If an error occurs, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+	} else {
+		// Otherwise, send the page of users with its pagination metadata.
+		models.SendPage(rw, users, models.NewPagination(opts, total, r.URL))
+	}
+}
+
+// GetUser handles the request to fetch a single user by their ID.
+// If the user is found, it sends the user data, otherwise sends a "Not Found" response.
+func (h *UserHandler) GetUser(rw http.ResponseWriter, r *http.Request) {
+	// Attempt to retrieve the user based on the request's ID.
+	if user, err := h.getUserByRequest(r); err != nil {
+		// If an error occurs, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+	} else {
+		// Otherwise, send the user data as the response.
+		sendUser(rw, user)
+	}
+}
+
+// CreateUser handles the request to create a new user.
+// It decodes the user data from the request body, saves the user to the database,
+// and sends the newly created user data as the response. The new user is
+// emailed a token to verify their address, see AccountHandler.VerifyEmail.
+func (h *UserHandler) CreateUser(rw http.ResponseWriter, r *http.Request) {
+	// Create an empty User object to hold the incoming data.
+	user := models.User{}
+	// Decode the request body into the User object, rejecting unknown fields.
+	if errs := validation.DecodeJSON(r.Body, &user); len(errs) > 0 {
+		// If the decoding fails, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	// The timestamps are set by the store, not by clients, and the address is not verified yet.
+	user.CreatedAt, user.UpdatedAt, user.DeletedAt, user.EmailVerifiedAt = time.Time{}, time.Time{}, nil, nil
+
+	// Only admins can choose the role of new users, everyone else signs up as a regular user.
+	if identity, _ := auth.IdentityFromContext(r.Context()); !identity.IsAdmin() || user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
+	// Make sure the user can be saved, otherwise list the invalid fields.
+	if !h.validate(rw, r, &user) {
+		return
+	}
+
+	if err := h.store.SaveUser(r.Context(), &user); err != nil {
+		// If the user cannot be saved, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+		return
+	}
+
+	// The user is created even when the email cannot be sent, they can ask for another one.
+	if err := h.accounts.SendVerification(r.Context(), &user); err != nil {
+		logging.FromContext(r.Context()).Error("verification email failed", "user_id", user.Id, "err", err)
+	}
+	// Send the newly created user data as the response.
+	sendUser(rw, user)
+}
+
+// DeleteUser handles the request to delete a user by their ID.
+// If the user is found, it soft deletes the user and sends the deleted user data as the response.
+// The user can be brought back with RestoreUser until it is purged.
+func (h *UserHandler) DeleteUser(rw http.ResponseWriter, r *http.Request) {
+	// Attempt to retrieve the user based on the request's ID, at the version the request expects.
+	if user, ok := h.getUserToChange(rw, r); !ok {
+		return
+	} else if err := h.store.DeleteUser(r.Context(), &user); err != nil {
+		// If the user cannot be deleted, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+	} else {
+		// Send the deleted user data as the response.
+		models.SendData(rw, user)
+	}
+}
+
+// RestoreUser handles the request to bring back a soft deleted user by their ID.
+// It fails with a 409 when another user has the username or email, which only
+// happens to users deleted before the unique indexes were added.
+func (h *UserHandler) RestoreUser(rw http.ResponseWriter, r *http.Request) {
+	// Attempt to retrieve the deleted user based on the request's ID.
+	user, err := h.getDeletedUserByRequest(r)
+	if err != nil {
+		// If the user cannot be retrieved, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+		return
+	}
+
+	// Make sure the username and email are still free, otherwise name the taken fields.
+	if err := user.ValidateUnique(r.Context(), h.store); err != nil {
+		models.SendError(rw, r, err)
+	} else if err := h.store.RestoreUser(r.Context(), &user); err != nil {
+		// If the user cannot be restored, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+	} else {
+		// Send the restored user data as the response.
+		sendUser(rw, user)
+	}
+}
+
+// PurgeUser handles the request to remove a user for good by their ID, whether
+// it was soft deleted or not. It sends the removed user data as the response.
+func (h *UserHandler) PurgeUser(rw http.ResponseWriter, r *http.Request) {
+	// Look for the user among the active users first, then among the deleted ones.
+	user, err := h.getUserByRequest(r)
+	if errors.Is(err, models.ErrNotFound) {
+		user, err = h.getDeletedUserByRequest(r)
+	}
+
+	if err != nil {
+		// If the user cannot be retrieved, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+	} else if err := h.store.PurgeUser(r.Context(), &user); err != nil {
+		// If the user cannot be removed, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+	} else {
+		// Send the removed user data as the response.
+		models.SendData(rw, user)
+	}
+}
+
+// UpdateUser handles the request to replace an existing user's data.
+// It retrieves the user based on the ID, decodes the new user data from the request body,
+// updates the user in the database, and sends the updated user data as the response.
+// Fields missing from the body are emptied, PatchUser only changes the fields it is sent.
+func (h *UserHandler) UpdateUser(rw http.ResponseWriter, r *http.Request) {
+	// Attempt to retrieve the user based on the request's ID, at the version the request expects.
+	current, ok := h.getUserToChange(rw, r)
+	if !ok {
+		return
+	}
+
+	// Create an empty User object to hold the updated data.
+	user := models.User{}
+	// Decode the new user data from the request body, rejecting unknown fields.
+	if errs := validation.DecodeJSON(r.Body, &user); len(errs) > 0 {
+		// If the decoding fails, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	h.saveChanges(rw, r, current, &user)
+}
+
+// PatchUser handles the request to change some fields of an existing user.
+// The body is either a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902),
+// as told by its Content-Type, applied to the user as GetUser sends it.
+// The patched user is then validated and saved like in UpdateUser.
+func (h *UserHandler) PatchUser(rw http.ResponseWriter, r *http.Request) {
+	// Tell clients which kinds of patch are accepted.
+	rw.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+
+	// Attempt to retrieve the user based on the request's ID, at the version the request expects.
+	current, ok := h.getUserToChange(rw, r)
+	if !ok {
+		return
+	}
+
+	// Read the patch from the request body.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		models.SendError(rw, r, apperr.BadRequest("The request body cannot be read"))
+		return
+	}
+
+	// Apply the patch to the user as clients see it, without the password hash.
+	document := current
+	document.Password = ""
+	original, err := json.Marshal(document)
+	if err != nil {
+		models.SendError(rw, r, apperr.Internal(err))
+		return
+	}
+	patched, err := patch.Apply(r.Header.Get("Content-Type"), original, body)
+	if err != nil {
+		// If the patch cannot be applied, send the response matching the kind of error.
+		models.SendError(rw, r, patchError(err))
+		return
+	}
+
+	// Decode the patched user, rejecting the fields a User does not have.
+	user := models.User{}
+	if errs := validation.DecodeJSON(bytes.NewReader(patched), &user); len(errs) > 0 {
+		// If the decoding fails, send an "Unprocessable Entity" response listing the problems.
+		models.SendError(rw, r, apperr.Validation(errs))
+		return
+	}
+
+	h.saveChanges(rw, r, current, &user)
+}
+
+// saveChanges saves the new data of the current user sent by UpdateUser or PatchUser.
+// It keeps the fields clients cannot change, validates the user and sends the
+// saved user as the response, or the error that prevented saving it.
+func (h *UserHandler) saveChanges(rw http.ResponseWriter, r *http.Request, current models.User, user *models.User) {
+	// Keep the ID, the timestamps and the version, they are set by the store.
+	user.Id, user.Version = current.Id, current.Version
+	user.CreatedAt, user.UpdatedAt, user.DeletedAt = current.CreatedAt, current.UpdatedAt, nil
+	// The address stays verified until it changes, then it needs a new verification.
+	user.EmailVerifiedAt = nil
+	if user.Email == current.Email {
+		user.EmailVerifiedAt = current.EmailVerifiedAt
+	}
+	// Keep the current password when the request does not send a new one,
+	// since clients never receive it and cannot send it back.
+	if user.Password == "" {
+		user.KeepPassword(current)
+	}
+	// Only admins can change roles, users cannot promote themselves.
+	if identity, _ := auth.IdentityFromContext(r.Context()); !identity.IsAdmin() || user.Role == "" {
+		user.Role = current.Role
+	}
+	// Make sure the user can be saved, otherwise list the invalid fields.
+	if !h.validate(rw, r, user) {
+		return
+	}
+
+	// Save the updated user, unless another request changed it since it was read.
+	if err := h.store.SaveUser(r.Context(), user); err != nil {
+		// If the user cannot be saved, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+		return
+	}
+	// Ask the user to verify their new address.
+	if user.Email != current.Email {
+		if err := h.accounts.SendVerification(r.Context(), user); err != nil {
+			logging.FromContext(r.Context()).Error("verification email failed", "user_id", user.Id, "err", err)
+		}
+	}
+	// Send the updated user data as the response.
+	sendUser(rw, *user)
+}
+
+// patchError returns the error sent to the client when a patch cannot be applied:
+// unsupported content types are a 415, malformed patches a 400, failed "test"
+// operations a 409 and operations on fields that do not exist a 422.
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, patch.ErrUnsupportedType):
+		return apperr.UnsupportedMediaType("The body must be a JSON merge patch (" + patch.MergePatchType + ") or a JSON patch (" + patch.JSONPatchType + ")")
+	case errors.Is(err, patch.ErrTestFailed):
+		return apperr.Conflict(err.Error())
+	case errors.Is(err, patch.ErrCannotApply):
+		return apperr.New(apperr.ErrValidation, err.Error())
+	default:
+		return apperr.BadRequest(err.Error())
+	}
+}
+
+// sendUser sends the user data as the response, with its version in the ETag header
+func sendUser(rw http.ResponseWriter, user models.User) {
+	rw.Header().Set("ETag", user.ETag())
+	models.SendData(rw, user)
+}
+
+// validate checks the user before it is saved. When it is not valid, it sends an
+// "Unprocessable Entity" response listing every invalid field, or a "Conflict"
+// naming the username or email already taken, and returns false.
+func (h *UserHandler) validate(rw http.ResponseWriter, r *http.Request, user *models.User) bool {
+	if err := user.Validate(r.Context(), h.store); err != nil {
+		// Send the invalid or taken fields, or an "Internal Server Error" if the uniqueness checks failed.
+		models.SendError(rw, r, err)
+		return false
+	}
+
+	return true
+}
+
+// getUserByRequest extracts the user ID from the request and retrieves the user from the store.
+// Returns the user and any error encountered during retrieval.
+func (h *UserHandler) getUserByRequest(r *http.Request) (models.User, error) {
+	// Get the user ID from the request's URL parameters.
+	vars := mux.Vars(r)
+	userId, _ := strconv.ParseInt(vars["id"], 10, 64)
+	// Retrieve the user from the store by ID.
+	if user, err := h.store.GetUser(r.Context(), userId); err != nil {
+		// If an error occurs, return the error.
+		return models.User{}, err
+	} else {
+		// Otherwise, return the user and nil error.
+		return *user, nil
+	}
+}
+
+// getUserToChange retrieves the user of the request like getUserByRequest, then
+// checks the If-Match header of the request against the ETag of the user. When
+// the user cannot be changed, it sends the error response and returns false.
+func (h *UserHandler) getUserToChange(rw http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, err := h.getUserByRequest(r)
+	if err == nil && !user.MatchesETag(r.Header.Get("If-Match")) {
+		// The client edited an older version of the user.
+		err = models.ErrVersionMismatch
+	}
+	if err != nil {
+		// If the user cannot be changed, send the response matching the kind of error.
+		models.SendError(rw, r, err)
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// getDeletedUserByRequest extracts the user ID from the request and retrieves the
+// soft deleted user with that ID from the store, or models.ErrNotFound.
+func (h *UserHandler) getDeletedUserByRequest(r *http.Request) (models.User, error) {
+	// Get the user ID from the request's URL parameters.
+	vars := mux.Vars(r)
+	opts := models.ListOptions{Page: 1, PerPage: 1, Deleted: true, Filters: []models.Filter{{Column: "id", Value: vars["id"]}}}
+	// Look for the user among the deleted users only.
+	users, _, err := h.store.ListUsers(r.Context(), opts)
+	if err != nil {
+		return models.User{}, err
+	}
+	if len(users) == 0 {
+		return models.User{}, models.ErrNotFound
+	}
+
+	return users[0], nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout returns a middleware that gives every request a deadline. The
+// handlers pass the request context down to the store, so a slow query is
+// canceled when the deadline passes (or the client goes away) and the
+// client gets a 504 Gateway Timeout instead of waiting forever.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// LongTimeout is like Timeout for requests that can take longer than the read
+// and write timeouts of the server, such as bulk imports and exports. It also
+// pushes back the deadlines of the connection to the end of the request.
+func LongTimeout(d time.Duration) func(http.Handler) http.Handler {
+	timeout := Timeout(d)
+	return func(next http.Handler) http.Handler {
+		next = timeout(next)
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			// Writers without deadlines, e.g. in tests, return an error that can be ignored
+			deadline := time.Now().Add(d)
+			controller := http.NewResponseController(rw)
+			controller.SetReadDeadline(deadline)
+			controller.SetWriteDeadline(deadline)
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
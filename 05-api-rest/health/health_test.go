@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestReady checks the status of the readiness probe with working, failing
+// and slow dependencies, and while draining.
+func TestReady(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	// Define a table of test cases with the checks, whether the server drains and the expected result
+	table := []struct {
+		name     string
+		checks   map[string]Check
+		drain    bool
+		status   int
+		report   string
+		mysqlErr string
+	}{
+		{"no dependency", map[string]Check{}, false, http.StatusOK, "ready", ""},
+		{"up", map[string]Check{"mysql": up}, false, http.StatusOK, "ready", ""},
+		{"down", map[string]Check{"mysql": down, "cache": up}, false, http.StatusServiceUnavailable, "unready", "unreachable"},
+		{"timeout", map[string]Check{"mysql": slow}, false, http.StatusServiceUnavailable, "unready", "timeout"},
+		{"draining", map[string]Check{"mysql": up}, true, http.StatusServiceUnavailable, "unready", ""},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		checker := NewChecker(50 * time.Millisecond)
+		for name, check := range item.checks {
+			checker.Add(name, check)
+		}
+		if item.drain {
+			checker.Drain()
+		}
+
+		rw := httptest.NewRecorder()
+		checker.Ready(rw, httptest.NewRequest("GET", ReadyPath, nil))
+		if rw.Code != item.status {
+			t.Errorf("Incorrect status for %s, got %d, expected %d", item.name, rw.Code, item.status)
+		}
+
+		report := Report{}
+		if err := json.Unmarshal(rw.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Incorrect report for %s %q: %v", item.name, rw.Body, err)
+		}
+		if report.Status != item.report || report.Draining != item.drain || len(report.Checks) != len(item.checks) {
+			t.Errorf("Incorrect report for %s, got %s", item.name, rw.Body)
+		}
+		if mysql, ok := report.Checks["mysql"]; ok && mysql.Error != item.mysqlErr {
+			t.Errorf("Incorrect error of mysql for %s, got %q, expected %q", item.name, mysql.Error, item.mysqlErr)
+		}
+	}
+}
+
+// TestLive checks that the liveness probe succeeds, even while draining.
+func TestLive(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("mysql", func(ctx context.Context) error { return errors.New("connection refused") })
+	checker.Drain()
+
+	rw := httptest.NewRecorder()
+	checker.Live(rw, httptest.NewRequest("GET", LivePath, nil))
+	if rw.Code != http.StatusOK {
+		t.Errorf("Incorrect status, got %d, expected %d", rw.Code, http.StatusOK)
+	}
+}
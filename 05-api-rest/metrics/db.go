@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queryDuration times the SQL statements run by the store, by type of statement
+var queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Time taken by the SQL statements, by type of statement.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"operation"})
+
+// operations are the types of statement labeled on their own, any other is "other"
+var operations = map[string]bool{"select": true, "insert": true, "update": true, "delete": true}
+
+// Operation returns the type of the SQL statement, e.g. "select", taken from
+// its first keyword
+func Operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) > 0 && operations[strings.ToLower(fields[0])] {
+		return strings.ToLower(fields[0])
+	}
+
+	return "other"
+}
+
+// ObserveQuery records the time elapsed since start for a statement of the
+// given type. Call it with defer before running the statement.
+func ObserveQuery(operation string, start time.Time) {
+	queryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDBStats publishes the state of a connection pool, read from stats
+// at every scrape, as metrics labeled with the name of the database.
+// Register each pool once.
+func RegisterDBStats(name string, stats func() sql.DBStats) {
+	labels := prometheus.Labels{"db": name}
+	gauge := func(metric, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: metric, Help: help, ConstLabels: labels}, func() float64 { return value(stats()) })
+	}
+	counter := func(metric, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: metric, Help: help, ConstLabels: labels}, func() float64 { return value(stats()) })
+	}
+
+	Registry.MustRegister(
+		gauge("db_max_open_connections", "Maximum number of open connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("db_open_connections", "Number of established connections, in use or idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("db_in_use_connections", "Number of connections currently in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("db_idle_connections", "Number of idle connections.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("db_wait_count_total", "Number of times a statement waited for a free connection.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("db_wait_duration_seconds_total", "Time spent waiting for a free connection.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	)
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// scrape returns the metrics as Prometheus would read them
+func scrape(t *testing.T) string {
+	rw := httptest.NewRecorder()
+	Handler().ServeHTTP(rw, httptest.NewRequest("GET", Path, nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("Incorrect status of the metrics, got %d, expected %d", rw.Code, http.StatusOK)
+	}
+
+	return rw.Body.String()
+}
+
+// TestMiddleware checks that requests are counted by route template and status.
+func TestMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/user/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "0" {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods("GET")
+	handler := Middleware(router)(router)
+
+	for _, path := range []string{"/api/user/1", "/api/user/2", "/api/user/0", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Define a table of test cases with the series expected in the metrics
+	table := []string{
+		`http_requests_total{method="GET",route="/api/user/{id:[0-9]+}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/api/user/{id:[0-9]+}",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/api/user/{id:[0-9]+}",status="200"} 2`,
+	}
+
+	// Loop through each test case
+	metrics := scrape(t)
+	for _, series := range table {
+		if !strings.Contains(metrics, series+"\n") {
+			t.Errorf("Incorrect metrics, %s is missing", series)
+		}
+	}
+}
+
+// TestQueries checks the type of the statements and the metrics of the pool.
+func TestQueries(t *testing.T) {
+	// Define a table of test cases with a statement and its type
+	table := []struct {
+		query, operation string
+	}{
+		{"SELECT COUNT(*) FROM users", "select"},
+		{"  insert INTO users VALUES (?)", "insert"},
+		{"UPDATE users SET deleted_at=NULL", "update"},
+		{"DELETE FROM users WHERE id=?", "delete"},
+		{"TRUNCATE TABLE users", "other"},
+		{"", "other"},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		if operation := Operation(item.query); operation != item.operation {
+			t.Errorf("Incorrect operation of %q, got %q, expected %q", item.query, operation, item.operation)
+		}
+		ObserveQuery(Operation(item.query), time.Now())
+	}
+
+	RegisterDBStats("test", func() sql.DBStats { return sql.DBStats{OpenConnections: 3, InUse: 2, Idle: 1, WaitCount: 5} })
+
+	metrics := scrape(t)
+	for _, series := range []string{
+		`db_query_duration_seconds_count{operation="select"} 1`,
+		`db_query_duration_seconds_count{operation="other"} 2`,
+		`db_open_connections{db="test"} 3`,
+		`db_in_use_connections{db="test"} 2`,
+		`db_idle_connections{db="test"} 1`,
+		`db_wait_count_total{db="test"} 5`,
+	} {
+		if !strings.Contains(metrics, series+"\n") {
+			t.Errorf("Incorrect metrics, %s is missing", series)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+-- Users of the API, formerly models.UserSchema
+CREATE TABLE IF NOT EXISTS users (
+	id INT(6) UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	email VARCHAR(50),
+	role VARCHAR(10) NOT NULL DEFAULT 'user',
+	create_data TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
@@ -0,0 +1,5 @@
+DROP INDEX users_deleted_at ON users;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users MODIFY created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users RENAME COLUMN created_at TO create_data;
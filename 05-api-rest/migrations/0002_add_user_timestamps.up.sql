@@ -0,0 +1,6 @@
+-- Creation, update and soft deletion times of the users
+ALTER TABLE users RENAME COLUMN create_data TO created_at;
+ALTER TABLE users MODIFY created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
+CREATE INDEX users_deleted_at ON users (deleted_at);
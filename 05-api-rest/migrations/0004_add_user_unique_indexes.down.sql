@@ -0,0 +1,2 @@
+DROP INDEX users_email ON users;
+DROP INDEX users_username ON users;
@@ -0,0 +1,4 @@
+-- Usernames and emails identify a single user. Soft deleted users keep theirs
+-- until they are purged, so restoring a user never clashes with another one.
+CREATE UNIQUE INDEX users_username ON users (username);
+CREATE UNIQUE INDEX users_email ON users (email);
@@ -0,0 +1,3 @@
+-- The role column also comes from 0001 on most databases, where dropping it
+-- would leave the table unlike the one 0001 creates, so it is kept.
+SELECT 1;
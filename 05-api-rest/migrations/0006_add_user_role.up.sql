@@ -0,0 +1,11 @@
+-- Role of the users. Tables created before roles existed kept their columns,
+-- since 0001 only creates the table when it is missing, so the column is
+-- added to those only: MySQL has no ADD COLUMN IF NOT EXISTS.
+SET @add_role = (SELECT IF(COUNT(*) = 0,
+	'ALTER TABLE users ADD COLUMN role VARCHAR(10) NOT NULL DEFAULT ''user''',
+	'SELECT 1')
+	FROM information_schema.columns
+	WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'role');
+PREPARE add_role FROM @add_role;
+EXECUTE add_role;
+DEALLOCATE PREPARE add_role;
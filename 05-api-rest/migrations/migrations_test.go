@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"migrate"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// testDSNEnv names the variable holding the DSN of a scratch MySQL database
+// for the tests applying the migrations. Its tables are dropped.
+const testDSNEnv = "MIGRATIONS_TEST_DSN"
+
+// legacyUsers is the users table of the first versions, before the migrations,
+// without the role column
+const legacyUsers = `CREATE TABLE users (
+	id INT(6) UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	email VARCHAR(50),
+	create_data TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`
+
+// TestFiles checks that every migration has statements to apply and revert.
+func TestFiles(t *testing.T) {
+	migrations, err := migrate.Load(Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range migrations {
+		if len(migrate.Statements(m.Up)) == 0 || len(migrate.Statements(m.Down)) == 0 {
+			t.Errorf("Incorrect migration %s, it needs statements in both files", m)
+		}
+	}
+}
+
+// TestAddUserRole checks that the migrations give the role column to a users
+// table created before roles, which 0001 leaves as it is, and that they can
+// be reverted and applied again.
+func TestAddUserRole(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("set %s to the DSN of a scratch MySQL database", testDSNEnv)
+	}
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Start from the legacy table holding a user
+	ctx := context.Background()
+	drop := "DROP TABLE IF EXISTS user_tokens, users, " + Table
+	for _, statement := range []string{drop, legacyUsers, "INSERT INTO users (username, password, email) VALUES ('alex', 'password1', 'alex@example.com')"} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer conn.ExecContext(ctx, drop)
+
+	migrator, err := migrate.New(conn, Files, Table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []string{"up", "down", "up"} {
+		if step == "up" {
+			_, err = migrator.Up(ctx)
+		} else {
+			_, err = migrator.Down(ctx, 1)
+		}
+		if err != nil {
+			t.Fatalf("Incorrect error migrating %s, got %v, expected none", step, err)
+		}
+	}
+
+	role := ""
+	if err := conn.QueryRowContext(ctx, "SELECT role FROM users WHERE username = 'alex'").Scan(&role); err != nil || role != "user" {
+		t.Errorf("Incorrect role of the existing user, got %q %v, expected %q", role, err, "user")
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strconv"
+	"time"
+	"validation"
+)
+
+// Formats of the bulk import and export of users
+const (
+	FormatCSV    = "csv"    // Comma separated values with a header line
+	FormatNDJSON = "ndjson" // Newline delimited JSON, one user object per line
+)
+
+// ImportBatchSize is the number of users inserted by every transaction of an import
+const ImportBatchSize = 500
+
+// ImportColumns are the columns accepted in the header of a CSV import, role is optional
+var ImportColumns = []string{"username", "password", "email", "role"}
+
+// ExportColumns are the columns of a CSV export, in the order of User.Record
+var ExportColumns = []string{"id", "username", "email", "role", "created_at", "updated_at", "deleted_at", "version"}
+
+// ImportReport is the result of a bulk import, sent as the response
+type ImportReport struct {
+	Imported int        `json:"imported"` // Number of users saved
+	Failed   int        `json:"failed"`   // Number of rows that were not saved
+	Errors   []RowError `json:"errors"`   // Why each failed row was not saved
+}
+
+// RowError lists the problems of a row that could not be imported
+type RowError struct {
+	Line   int               `json:"line"`   // Line of the row in the body, starting at 1
+	Errors validation.Errors `json:"errors"` // Invalid fields of the row, "row" when the row as a whole failed
+}
+
+// NewImportReport returns an empty report
+func NewImportReport() *ImportReport {
+	return &ImportReport{Errors: []RowError{}}
+}
+
+// Fail records that the row at the given line was not imported
+func (report *ImportReport) Fail(line int, errs validation.Errors) {
+	report.Failed++
+	report.Errors = append(report.Errors, RowError{Line: line, Errors: errs})
+}
+
+// Record returns the user as a CSV record with the columns of ExportColumns.
+// The password is never exported.
+func (user *User) Record() []string {
+	deletedAt := ""
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.Format(time.RFC3339)
+	}
+
+	return []string{
+		strconv.FormatInt(user.Id, 10),
+		user.Username,
+		user.Email,
+		user.Role,
+		user.CreatedAt.Format(time.RFC3339),
+		user.UpdatedAt.Format(time.RFC3339),
+		deletedAt,
+		strconv.FormatInt(user.Version, 10),
+	}
+}
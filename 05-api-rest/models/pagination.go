@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Default and maximum page sizes used when listing users
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// userColumns lists the columns that can be used to sort or filter users.
+// Only these names are ever placed into the generated SQL, so query
+// parameters can never inject arbitrary column names.
+var userColumns = map[string]string{
+	"id":       "id",
+	"username": "username",
+	"email":    "email",
+	"role":     "role",
+	// Only used to sort
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// SortField represents a single column of the ORDER BY clause
+type SortField struct {
+	Column string // Column to sort by (must be one of userColumns)
+	Desc   bool   // True to sort in descending order
+}
+
+// Filter represents a single condition of the WHERE clause
+type Filter struct {
+	Column string // Column to filter by (must be one of userColumns)
+	Value  string // Value the column is compared against
+	Like   bool   // True to match the value as a substring instead of exactly
+}
+
+// ListOptions holds the pagination, sorting and filtering options used to list users
+type ListOptions struct {
+	Page    int         // Page number, starting at 1
+	PerPage int         // Number of users per page
+	Sort    []SortField // Sort order, applied in sequence
+	Filters []Filter    // Filters, combined with AND
+	Deleted bool        // List the soft deleted users instead of the active ones
+}
+
+// Pagination holds the metadata returned alongside a page of results
+type Pagination struct {
+	Page       int    `json:"page"`           // Current page number
+	PerPage    int    `json:"per_page"`       // Number of items per page
+	Total      int    `json:"total"`          // Total number of items matching the filters
+	TotalPages int    `json:"total_pages"`    // Total number of pages
+	Next       string `json:"next,omitempty"` // Link to the next page, if any
+	Prev       string `json:"prev,omitempty"` // Link to the previous page, if any
+}
+
+// NewListOptions returns ListOptions with the default page and page size
+func NewListOptions() ListOptions {
+	return ListOptions{Page: 1, PerPage: DefaultPerPage}
+}
+
+// Offset returns the number of rows to skip for the current page
+func (opts ListOptions) Offset() int {
+	return (opts.Page - 1) * opts.PerPage
+}
+
+// ParseListOptions builds ListOptions from the query string of a request.
+// It understands ?page=, ?per_page=, ?sort=username,-id and field filters
+// such as ?username= (exact match) or ?email_like= (substring match).
+// ?deleted=true lists the soft deleted users instead of the active ones.
+func ParseListOptions(values url.Values) (ListOptions, error) {
+	opts := NewListOptions()
+
+	// Parse the page number, it must be a positive integer
+	if page := values.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return opts, errors.New("page must be a positive integer")
+		}
+		opts.Page = n
+	}
+
+	// Parse the page size, it must be between 1 and MaxPerPage
+	if perPage := values.Get("per_page"); perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 1 || n > MaxPerPage {
+			return opts, fmt.Errorf("per_page must be an integer between 1 and %d", MaxPerPage)
+		}
+		opts.PerPage = n
+	}
+
+	// Parse the comma separated sort list, a leading "-" means descending
+	if sort := values.Get("sort"); sort != "" {
+		for _, field := range strings.Split(sort, ",") {
+			desc := strings.HasPrefix(field, "-")
+			name := strings.TrimPrefix(field, "-")
+			if _, ok := userColumns[name]; !ok {
+				return opts, fmt.Errorf("cannot sort by %q", name)
+			}
+			opts.Sort = append(opts.Sort, SortField{Column: name, Desc: desc})
+		}
+	}
+
+	// Parse the choice between active and deleted users
+	if deleted := values.Get("deleted"); deleted != "" {
+		b, err := strconv.ParseBool(deleted)
+		if err != nil {
+			return opts, errors.New("deleted must be true or false")
+		}
+		opts.Deleted = b
+	}
+
+	// Parse the field filters, in a stable order so the SQL is deterministic
+	for _, name := range []string{"id", "username", "email", "role"} {
+		if value := values.Get(name); value != "" {
+			opts.Filters = append(opts.Filters, Filter{Column: name, Value: value})
+		}
+		if value := values.Get(name + "_like"); value != "" {
+			opts.Filters = append(opts.Filters, Filter{Column: name, Value: value, Like: true})
+		}
+	}
+
+	return opts, nil
+}
+
+// WhereClause builds the SQL WHERE clause and its arguments for the filters.
+// It always keeps either the active or the soft deleted users, see Deleted.
+func (opts ListOptions) WhereClause() (string, []interface{}) {
+	conditions := make([]string, 0, len(opts.Filters)+1)
+	if opts.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	args := make([]interface{}, 0, len(opts.Filters))
+	for _, filter := range opts.Filters {
+		column := userColumns[filter.Column]
+		if filter.Like {
+			conditions = append(conditions, column+" LIKE ? ESCAPE '!'")
+			args = append(args, "%"+escapeLike(filter.Value)+"%")
+		} else {
+			conditions = append(conditions, column+"=?")
+			args = append(args, filter.Value)
+		}
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// OrderClause builds the SQL ORDER BY clause, sorting by id when nothing was requested
+func (opts ListOptions) OrderClause() string {
+	if len(opts.Sort) == 0 {
+		return " ORDER BY id"
+	}
+
+	fields := make([]string, 0, len(opts.Sort))
+	for _, sort := range opts.Sort {
+		field := userColumns[sort.Column]
+		if sort.Desc {
+			field += " DESC"
+		}
+		fields = append(fields, field)
+	}
+
+	return " ORDER BY " + strings.Join(fields, ", ")
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern using "!"
+// as the escape character, which MySQL and SQLite both accept
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return replacer.Replace(value)
+}
+
+// NewPagination computes the pagination metadata for a page of results.
+// The link to a page is built by replacing the page parameter of the given URL.
+func NewPagination(opts ListOptions, total int, link *url.URL) *Pagination {
+	pagination := &Pagination{
+		Page:       opts.Page,
+		PerPage:    opts.PerPage,
+		Total:      total,
+		TotalPages: (total + opts.PerPage - 1) / opts.PerPage,
+	}
+
+	// Helper to build the link to a given page, keeping the other parameters
+	pageLink := func(page int) string {
+		u := *link
+		query := u.Query()
+		query.Set("page", strconv.Itoa(page))
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	if opts.Page < pagination.TotalPages {
+		pagination.Next = pageLink(opts.Page + 1)
+	}
+	if opts.Page > 1 {
+		pagination.Prev = pageLink(min(opts.Page-1, max(pagination.TotalPages, 1)))
+	}
+
+	return pagination
+}
@@ -1,90 +1,168 @@
-package models
-
-import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-)
-
-// Response represents the standard structure for HTTP responses.
-// It includes the status code, data to be returned, and any additional message.
-type Response struct {
-	Status      int                 `json:"status"`  // HTTP status code
-	Data        interface{}         `json:"data"`    // Data to be returned in the response body
-	Message     string              `json:"message"` // Message providing additional context (e.g., error message)
-	contentType string              // Content type of the response (usually "application/json")
-	respWrite   http.ResponseWriter // The response writer to send the response
-}
-
-// CreateDefaultResponse initializes a Response with default values.
-// The status is set to OK (200), content type is set to "application/json",
-// and it takes the ResponseWriter to send the response.
-func CreateDefaultResponse(rw http.ResponseWriter) Response {
-	return Response{
-		Status:      http.StatusOK,      // Default status is 200 OK
-		respWrite:   rw,                 // Response writer to send the response
-		contentType: "application/json", // Default content type is JSON
-	}
-}
-
-// Send sends the Response to the client.
-// It sets the response headers, marshals the Response struct to JSON,
-// and writes the response to the client.
-func (resp *Response) Send() {
-	// Set the Content-Type header for the response
-	resp.respWrite.Header().Set("Content-Type", resp.contentType)
-	// Set the HTTP status code for the response
-	resp.respWrite.WriteHeader(resp.Status)
-
-	// Marshal the response to JSON
-	output, _ := json.Marshal(&resp)
-	// Write the JSON output to the response body
-	fmt.Fprintln(resp.respWrite, string(output))
-}
-
-// SendData creates a default Response, assigns the provided data to it,
-// and sends it as the response to the client.
-func SendData(rw http.ResponseWriter, data interface{}) {
-	// Create a default Response with the provided ResponseWriter
-	response := CreateDefaultResponse(rw)
-	// Assign the data to the Response
-	response.Data = data
-	// Send the response to the client
-	response.Send()
-}
-
-// NotFound sets the Response status to HTTP 404 (Not Found)
-// and adds a default "Resource not found" message.
-func (resp *Response) NotFound() {
-	resp.Status = http.StatusNotFound   // Set status code to 404
-	resp.Message = "Resource not found" // Set the default not found message
-}
-
-// SendNotFound creates a default Response, sets it to "Not Found" (404),
-// and sends the response to the client.
-func SendNotFound(rw http.ResponseWriter) {
-	// Create a default Response
-	response := CreateDefaultResponse(rw)
-	// Set the response to "Not Found"
-	response.NotFound()
-	// Send the "Not Found" response to the client
-	response.Send()
-}
-
-// UnprocessableEntity sets the Response status to HTTP 422 (Unprocessable Entity)
-// and adds a default "UnprocessableEntity not found" message.
-func (resp *Response) UnprocessableEntity() {
-	resp.Status = http.StatusUnprocessableEntity   // Set status code to 422
-	resp.Message = "UnprocessableEntity not found" // Set the default unprocessable entity message
-}
-
-// SendUnprocessableEntity creates a default Response, sets it to "Unprocessable Entity" (422),
-// and sends the response to the client.
-func SendUnprocessableEntity(rw http.ResponseWriter) {
-	// Create a default Response
-	response := CreateDefaultResponse(rw)
-	// Set the response to "Unprocessable Entity"
-	response.UnprocessableEntity()
-	// Send the "Unprocessable Entity" response to the client
-	response.Send()
-}
+package models
+
+import (
+	"apperr"
+	"encoding/json"
+	"fmt"
+	"logging"
+	"net/http"
+	"strings"
+	"validation"
+)
+
+// problemContentType is the content type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// Response represents the standard structure for HTTP responses.
+// It includes the status code, data to be returned, and any additional message.
+type Response struct {
+	Status      int                 `json:"status"`               // HTTP status code
+	Data        interface{}         `json:"data"`                 // Data to be returned in the response body
+	Message     string              `json:"message"`              // Message providing additional context (e.g., error message)
+	Meta        *Pagination         `json:"meta,omitempty"`       // Pagination metadata, only set for paginated lists
+	Errors      validation.Errors   `json:"errors,omitempty"`     // Invalid fields, only set when validation fails
+	Code        string              `json:"code,omitempty"`       // Machine readable error code, only set for errors
+	RequestId   string              `json:"request_id,omitempty"` // ID of the request, only set for errors
+	contentType string              // Content type of the response (usually "application/json")
+	respWrite   http.ResponseWriter // The response writer to send the response
+}
+
+// CreateDefaultResponse initializes a Response with default values.
+// The status is set to OK (200), content type is set to "application/json",
+// and it takes the ResponseWriter to send the response.
+func CreateDefaultResponse(rw http.ResponseWriter) Response {
+	return Response{
+		Status:      http.StatusOK,      // Default status is 200 OK
+		respWrite:   rw,                 // Response writer to send the response
+		contentType: "application/json", // Default content type is JSON
+	}
+}
+
+// Send sends the Response to the client.
+// It sets the response headers, marshals the Response struct to JSON,
+// and writes the response to the client.
+func (resp *Response) Send() {
+	// Set the Content-Type header for the response
+	resp.respWrite.Header().Set("Content-Type", resp.contentType)
+	// Set the HTTP status code for the response
+	resp.respWrite.WriteHeader(resp.Status)
+
+	// Marshal the response to JSON
+	output, _ := json.Marshal(&resp)
+	// Write the JSON output to the response body
+	fmt.Fprintln(resp.respWrite, string(output))
+}
+
+// SendData creates a default Response, assigns the provided data to it,
+// and sends it as the response to the client.
+func SendData(rw http.ResponseWriter, data interface{}) {
+	// Create a default Response with the provided ResponseWriter
+	response := CreateDefaultResponse(rw)
+	// Assign the data to the Response
+	response.Data = data
+	// Send the response to the client
+	response.Send()
+}
+
+// SendMessage creates a Response without data, with the given status and a
+// message for the client, e.g. a 202 telling that an email is on its way.
+func SendMessage(rw http.ResponseWriter, status int, message string) {
+	// Create a default Response with the provided ResponseWriter
+	response := CreateDefaultResponse(rw)
+	// Assign the status and the message to the Response
+	response.Status = status
+	response.Message = message
+	// Send the response to the client
+	response.Send()
+}
+
+// SendPage creates a default Response with one page of data and its
+// pagination metadata, and sends it as the response to the client.
+func SendPage(rw http.ResponseWriter, data interface{}, meta *Pagination) {
+	// Create a default Response with the provided ResponseWriter
+	response := CreateDefaultResponse(rw)
+	// Assign the data and the pagination metadata to the Response
+	response.Data = data
+	response.Meta = meta
+	// Send the response to the client
+	response.Send()
+}
+
+// Error sets the Response status, code and message from the kind of err, see
+// apperr.Status and apperr.Code. Errors of unknown kind are treated as internal
+// errors, so their details never reach the client.
+func (resp *Response) Error(err error, requestId string) {
+	appErr := apperr.From(err)
+	resp.Status = apperr.Status(appErr) // Set the status code matching the kind of error
+	resp.Code = apperr.Code(appErr)     // Set the machine readable code
+	resp.Message = appErr.Message       // Set the message safe to show to clients
+	resp.Errors = appErr.Fields         // List the invalid fields, if any
+	resp.RequestId = requestId          // Let the client quote the request when reporting the problem
+}
+
+// Problem is the RFC 7807 representation of an error, sent instead of
+// Response to clients that accept "application/problem+json".
+type Problem struct {
+	Type      string            `json:"type"`             // URI identifying the kind of problem
+	Title     string            `json:"title"`            // Short summary of the kind of problem
+	Status    int               `json:"status"`           // HTTP status code
+	Detail    string            `json:"detail"`           // Explanation of this occurrence of the problem
+	Instance  string            `json:"instance"`         // Path of the request that failed
+	Code      string            `json:"code"`             // Machine readable error code
+	RequestId string            `json:"request_id"`       // ID of the request
+	Errors    validation.Errors `json:"errors,omitempty"` // Invalid fields, only set when validation fails
+}
+
+// init makes the shared middlewares, e.g. auth.Require, send their errors
+// with SendError, so every error of the API has the same body
+func init() {
+	apperr.Writer = SendError
+}
+
+// SendError sends err to the client as the response. The status and code
+// depend on the kind of err, and the body includes the ID of the request.
+// Clients accepting "application/problem+json" get an RFC 7807 problem,
+// everyone else gets the usual Response. Server side errors are logged,
+// since their cause is not sent to the client.
+func SendError(rw http.ResponseWriter, r *http.Request, err error) {
+	requestId := apperr.RequestId(rw, r)
+
+	// Create a default Response and fill it in from the error
+	response := CreateDefaultResponse(rw)
+	response.Error(err, requestId)
+
+	// Log server side errors, the client only gets a generic message
+	if response.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+	} else if response.Status == apperr.StatusClientClosedRequest {
+		// Nothing went wrong on the server side, the client went away
+		logging.FromContext(r.Context()).Info("request canceled", "method", r.Method, "path", r.URL.Path)
+	}
+
+	// Ask the client to authenticate with a bearer token
+	if response.Status == http.StatusUnauthorized {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), problemContentType) {
+		// Send the error as a regular Response
+		response.Send()
+		return
+	}
+
+	// Send the error as an RFC 7807 problem
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     apperr.StatusText(response.Status),
+		Status:    response.Status,
+		Detail:    response.Message,
+		Instance:  r.URL.Path,
+		Code:      response.Code,
+		RequestId: requestId,
+		Errors:    response.Errors,
+	}
+	rw.Header().Set("Content-Type", problemContentType)
+	rw.WriteHeader(problem.Status)
+	output, _ := json.Marshal(&problem)
+	fmt.Fprintln(rw, string(output))
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"apperr"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+	"validation"
+)
+
+// Token is a one-time token sent to a user by email, e.g. to verify their
+// address. The signed token itself only travels by email, the store keeps its
+// ID so it can be used once and revoked when a newer one is sent.
+type Token struct {
+	Id        string     // ID of the token, the jti claim of the signed token
+	UserId    int64      // User the token was issued for
+	Purpose   string     // Kind of the signed token, e.g. auth.VerifyEmailToken
+	Email     string     // Address the token was sent to
+	ExpiresAt time.Time  // When the token stops being accepted
+	UsedAt    *time.Time // When the token was used or revoked, nil until then
+	CreatedAt time.Time  // When the token was issued
+}
+
+// ErrInvalidToken is returned when a token sent by email is malformed,
+// expired, already used or revoked. It is sent to clients as a 422.
+var ErrInvalidToken = apperr.Validation(validation.Errors{{Field: "token", Reason: "is invalid or expired"}})
+
+// TokenStore keeps the one-time tokens sent to the users by email.
+// Every method stops waiting for the database when ctx is canceled.
+type TokenStore interface {
+	// SaveToken stores a new token, the tokens that expired are removed
+	SaveToken(ctx context.Context, token *Token) error
+	// UseToken marks the unused and unexpired token with the given ID and
+	// purpose as used and returns it, or ErrInvalidToken. A token can only be
+	// used once, even by two requests racing for it.
+	UseToken(ctx context.Context, id, purpose string) (*Token, error)
+	// RevokeTokens marks every unused token of the user with the given purpose
+	// as used, e.g. once a newer one is sent
+	RevokeTokens(ctx context.Context, userId int64, purpose string) error
+}
+
+// NewToken creates an unused token of the given purpose for the user, sent to
+// their current address and valid for ttl, with a random ID of 32 hexadecimal characters
+func NewToken(user *User, purpose string, ttl time.Duration) *Token {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+
+	now := Now()
+	return &Token{Id: hex.EncodeToString(buf), UserId: user.Id, Purpose: purpose, Email: user.Email, ExpiresAt: now.Add(ttl), CreatedAt: now}
+}
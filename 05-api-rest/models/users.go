@@ -1,97 +1,229 @@
-package models
-
-import (
-	"apirest/db"
-	"fmt"
-)
-
-// User struct represents a user in the database
-type User struct {
-	Id       int64  `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
-}
-
-// Users type represents a list of User
-type Users []User
-
-// UserSchema defines the SQL statement to create the "users" table
-const UserSchema = `CREATE TABLE users (
-	id INT(6) UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-	username VARCHAR(30) NOT NULL,
-	password VARCHAR(100) NOT NULL,
-	email VARCHAR(50),
-	create_data TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`
-
-// NewUser creates and returns a new User instance with the provided details
-func NewUser(username, password, email string) *User {
-	user := &User{Username: username, Password: password, Email: email}
-	return user
-}
-
-// Private method to insert a new user into the database
-func (user *User) insert() {
-	sql := "INSERT users SET username=?, password=?, email=?"
-	result, _ := db.Exec(sql, user.Username, user.Password, user.Email)
-	user.Id, _ = result.LastInsertId()
-}
-
-// CreateUser creates a new user, saves it in the database, and returns it
-func CreateUser(username, password, email string) *User {
-	user := NewUser(username, password, email)
-	user.Save()
-	return user
-}
-
-// ListUsers retrieves and returns all users from the database
-func ListUsers() (Users, error) {
-	sql := "SELECT id, username, password, email FROM users"
-	users := Users{}
-	rows, err := db.Query(sql)
-
-	for rows.Next() {
-		user := User{}
-		rows.Scan(&user.Id, &user.Username, &user.Password, &user.Email)
-		users = append(users, user)
-	}
-
-	return users, err
-}
-
-// GetUser retrieves a single user by ID from the database
-func GetUser(id int) (*User, error) {
-	user := NewUser("", "", "")
-	sql := "SELECT id, username, password, email FROM users WHERE id=?"
-	if rows, err := db.Query(sql, id); err != nil {
-		return nil, err
-	} else {
-		for rows.Next() {
-			rows.Scan(&user.Id, &user.Username, &user.Password, &user.Email)
-		}
-
-		return user, nil
-	}
-}
-
-// update modifies an existing user in the database
-func (user *User) update() {
-	sql := "UPDATE users SET username=?, password=?, email=? WHERE id=?"
-	db.Exec(sql, user.Username, user.Password, user.Email, user.Id)
-}
-
-// Save checks if a user already exists (by ID) and either inserts or updates it in the database
-func (user *User) Save() {
-	if user.Id == 0 {
-		user.insert()
-	} else {
-		user.update()
-	}
-}
-
-// Delete removes a user from the database by ID and prints a confirmation
-func (user *User) Delete() {
-	sql := "DELETE FROM users WHERE id=?"
-	db.Exec(sql, user.Id)
-	fmt.Printf("User with Id: %d deleted\n", user.Id)
-}
+package models
+
+import (
+	"apperr"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	"validation"
+)
+
+// User struct represents a user in the database
+// The validate tags mirror the columns of the users table (see migrations), see Validate.
+type User struct {
+	Id       int64  `json:"id"`
+	Username string `json:"username" validate:"required,max=30"`
+	Password string `json:"password,omitempty" validate:"required,min=8,max=72"` // Only read from requests, never serialized
+	Email    string `json:"email" validate:"required,email,max=50"`
+	Role     string `json:"role" validate:"oneof=admin user"` // RoleAdmin or RoleUser
+
+	// Set by the store, the values sent by clients are ignored
+	CreatedAt       time.Time  `json:"created_at"`                  // When the user was created
+	UpdatedAt       time.Time  `json:"updated_at"`                  // When the user was last saved
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`        // When the user was deleted, nil for active users
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"` // When the user proved they own the email, nil until then
+	Version         int64      `json:"version"`                     // Incremented on every save, see ETag
+
+	storedPassword string // Password as read from the store, see HashPassword
+}
+
+// Roles a user can have
+const (
+	RoleAdmin = "admin" // Can list, update and delete every user
+	RoleUser  = "user"  // Can only read and update their own record
+)
+
+// Users type represents a list of User
+type Users []User
+
+// ErrNotFound is returned by a UserStore when the requested user does not exist.
+// It is of kind apperr.ErrNotFound, so it is sent to clients as a 404.
+var ErrNotFound = apperr.NotFound("User not found")
+
+// ErrVersionMismatch is returned when a user was changed since the version the
+// request is based on was read. It is sent to clients as a 412.
+var ErrVersionMismatch = apperr.PreconditionFailed("The user was changed by another request, fetch it again")
+
+// UserStore is the storage backend used to persist users.
+// It lets the handlers work the same way on top of MySQL, SQLite or memory.
+// Every method stops waiting for the database when ctx is canceled.
+type UserStore interface {
+	TokenStore // Keeps the one-time tokens sent to the users by email
+
+	// ListUsers returns one page of users and the total number of users matching opts
+	ListUsers(ctx context.Context, opts ListOptions) (Users, int, error)
+	// GetUser returns the user with the given ID, or ErrNotFound
+	GetUser(ctx context.Context, id int64) (*User, error)
+	// GetUserByUsername returns the user with the given username, or ErrNotFound
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	// GetUserByEmail returns the active user with the given email, or ErrNotFound
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	// SaveUser inserts the user when its ID is 0, otherwise updates it. Updates
+	// only succeed when the stored version is still user.Version, otherwise they
+	// return ErrVersionMismatch. The version is incremented on every save.
+	SaveUser(ctx context.Context, user *User) error
+	// DeleteUser soft deletes the user with the ID of the given user: it is
+	// hidden from ListUsers and GetUser until RestoreUser is called. Like
+	// SaveUser, it returns ErrVersionMismatch when the stored version is not
+	// user.Version anymore.
+	DeleteUser(ctx context.Context, user *User) error
+	// RestoreUser brings back a soft deleted user, or returns ErrNotFound
+	RestoreUser(ctx context.Context, user *User) error
+	// PurgeUser removes the user for good, deleted or not, or returns ErrNotFound
+	PurgeUser(ctx context.Context, user *User) error
+	// ImportUsers inserts new users in a single transaction: either every user
+	// is saved, and gets its ID, or none is
+	ImportUsers(ctx context.Context, users Users) error
+	// ExportUsers calls fn with every user matching the filters and sort order
+	// of opts, ignoring the pagination, one user at a time so they are never all
+	// held in memory. It stops at the first error returned by fn.
+	ExportUsers(ctx context.Context, opts ListOptions, fn func(User) error) error
+}
+
+// Now returns the current time as stored in the database, in UTC and
+// rounded to the second like MySQL TIMESTAMP columns
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// NewUser creates and returns a new User instance with the provided details
+func NewUser(username, password, email string) *User {
+	user := &User{Username: username, Password: password, Email: email, Role: RoleUser}
+	return user
+}
+
+// IsAdmin reports whether the user has the admin role
+func (user *User) IsAdmin() bool {
+	return user.Role == RoleAdmin
+}
+
+// ETag returns the entity tag of the current version of the user, e.g. "3",
+// sent in the ETag header and compared with the If-Match header of requests
+func (user *User) ETag() string {
+	return strconv.Quote(strconv.FormatInt(user.Version, 10))
+}
+
+// MatchesETag reports whether the If-Match header allows changing the user:
+// it lists the ETag of the user or is "*". An empty header always matches.
+func (user *User) MatchesETag(ifMatch string) bool {
+	if ifMatch == "" {
+		return true
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == user.ETag() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDeleted reports whether the user has been soft deleted
+func (user *User) IsDeleted() bool {
+	return user.DeletedAt != nil
+}
+
+// IsEmailVerified reports whether the user proved they own their email address
+func (user *User) IsEmailVerified() bool {
+	return user.EmailVerifiedAt != nil
+}
+
+// BeforeSave prepares the user to be stored: it hashes the password, gives
+// the default role to users without one and sets the timestamps. Every
+// UserStore calls it.
+func (user *User) BeforeSave() error {
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+
+	user.UpdatedAt = Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = user.UpdatedAt
+	}
+
+	return user.HashPassword()
+}
+
+// UniqueColumns are the columns no two users can share, soft deleted users
+// included until they are purged. The users table has a unique index on each
+// of them, named "users_" followed by the column, see the migrations.
+var UniqueColumns = []string{"username", "email"}
+
+// Validate checks the user against the rules of its validate tags and makes
+// sure no other user already has the same username or email.
+// It returns nil when the user can be saved, an apperr.ErrValidation error
+// listing the invalid fields, or an apperr.ErrConflict error naming the taken
+// ones, see ValidateUnique.
+func (user *User) Validate(ctx context.Context, store UserStore) error {
+	if errs := validation.Struct(user); len(errs) > 0 {
+		return apperr.Validation(errs)
+	}
+
+	return user.ValidateUnique(ctx, store)
+}
+
+// ValidateUnique only makes sure no other user, active or soft deleted, has the
+// same username or email, e.g. before restoring a soft deleted user. The taken
+// fields are returned as an apperr.Duplicate error, sent to clients as a 409.
+// The unique indexes of the store still catch two requests racing for a value.
+func (user *User) ValidateUnique(ctx context.Context, store UserStore) error {
+	taken := []string{}
+	for _, column := range UniqueColumns {
+		found, err := isTaken(ctx, store, column, user.Field(column), user.Id)
+		if err != nil {
+			return err
+		}
+		if found {
+			taken = append(taken, column)
+		}
+	}
+
+	if len(taken) > 0 {
+		return apperr.Duplicate(nil, taken...)
+	}
+
+	return nil
+}
+
+// isTaken reports whether a user other than the one with the given ID has the
+// value in the column, looking at the active users then at the deleted ones
+func isTaken(ctx context.Context, store UserStore, column, value string, id int64) (bool, error) {
+	for _, deleted := range []bool{false, true} {
+		opts := ListOptions{Page: 1, PerPage: 2, Filters: []Filter{{Column: column, Value: value}}, Deleted: deleted}
+		users, _, err := store.ListUsers(ctx, opts)
+		if err != nil {
+			return false, err
+		}
+
+		for _, other := range users {
+			if other.Id != id {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Field returns the value of the column with the given name as a string.
+// It is used by stores that filter users in memory.
+func (user *User) Field(column string) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(user.Id, 10)
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	case "role":
+		return user.Role
+	case "created_at":
+		return user.CreatedAt.Format(time.RFC3339)
+	case "updated_at":
+		return user.UpdatedAt.Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
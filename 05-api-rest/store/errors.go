@@ -0,0 +1,60 @@
+package store
+
+import (
+	"apirest/models"
+	"apperr"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erDupEntry is the number of the MySQL error raised when a statement would
+// store a value already present in a unique index
+const erDupEntry = 1062
+
+// writeError translates the error of a statement writing users. A username or
+// email already taken is an apperr.Duplicate error naming the field, sent to
+// clients as a 409, anything else is an internal error.
+func writeError(err error) error {
+	if column, ok := duplicateColumn(err); ok {
+		if column == "" {
+			return apperr.Duplicate(err)
+		}
+		return apperr.Duplicate(err, column)
+	}
+
+	return apperr.Internal(err)
+}
+
+// duplicateColumn reports whether err was raised by a unique index of the users
+// table and returns the column of the index, or "" when it cannot be told.
+// MySQL names the index, e.g. "Duplicate entry 'kim' for key 'users.users_username'",
+// while SQLite names the column, e.g. "UNIQUE constraint failed: users.username".
+func duplicateColumn(err error) (string, bool) {
+	var key string
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &mysqlErr) && mysqlErr.Number == erDupEntry:
+		// The duplicate value comes first and could contain anything, so the key is after the last " for key "
+		if i := strings.LastIndex(mysqlErr.Message, " for key "); i >= 0 {
+			key = mysqlErr.Message[i+len(" for key "):]
+		}
+	case strings.Contains(err.Error(), "UNIQUE constraint failed: "):
+		_, key, _ = strings.Cut(err.Error(), "UNIQUE constraint failed: ")
+		key, _, _ = strings.Cut(key, " ")
+	default:
+		return "", false
+	}
+
+	// Keep the name after the table, then the column after the "users_" prefix of the indexes
+	key = strings.Trim(key, "'`\" ")
+	key = key[strings.LastIndex(key, ".")+1:]
+	column := strings.TrimPrefix(key, "users_")
+	if !slices.Contains(models.UniqueColumns, column) {
+		return "", true
+	}
+
+	return column, true
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"apirest/models"
+	"apperr"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TestDuplicateColumn checks that the errors of the unique indexes are told
+// from the other errors and give the column of the index
+func TestDuplicateColumn(t *testing.T) {
+	// Define a table of test cases with an error and the expected column
+	table := []struct {
+		err       error
+		column    string
+		duplicate bool
+	}{
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'kim' for key 'users.users_username'"}, "username", true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'kim@example.com' for key 'users_email'"}, "email", true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x for key users_email' for key 'users.users_username'"}, "username", true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '7' for key 'PRIMARY'"}, "", true},
+		{&mysql.MySQLError{Number: 1146, Message: "Table 'goweb_db.users' doesn't exist"}, "", false},
+		{errors.New("constraint failed: UNIQUE constraint failed: users.email (2067)"), "email", true},
+		{errors.New("sql: connection is already closed"), "", false},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		column, duplicate := duplicateColumn(item.err)
+		if column != item.column || duplicate != item.duplicate {
+			t.Errorf("Incorrect column for %q, got %q %v, expected %q %v", item.err, column, duplicate, item.column, item.duplicate)
+		}
+	}
+}
+
+// TestSQLiteDuplicates checks that the unique indexes of SQLite refuse a
+// taken username or email, soft deleted users included, with a conflict
+func TestSQLiteDuplicates(t *testing.T) {
+	ctx := context.Background()
+	userStore, err := NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userStore.Close()
+
+	// Store a user, then delete it, its username and email stay taken
+	alex := models.NewUser("alex", "password1", "alex@example.com")
+	if err := userStore.SaveUser(ctx, alex); err != nil {
+		t.Fatal(err)
+	}
+	if err := userStore.DeleteUser(ctx, alex); err != nil {
+		t.Fatal(err)
+	}
+
+	// Define a table of test cases with a new user and the field expected to be taken
+	table := []struct {
+		user  *models.User
+		field string
+	}{
+		{models.NewUser("alex", "password1", "alex@example.org"), "username"},
+		{models.NewUser("sam", "password1", "alex@example.com"), "email"},
+		{models.NewUser("sam", "password1", "sam@example.com"), ""},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		err := userStore.SaveUser(ctx, item.user)
+
+		var appErr *apperr.Error
+		switch {
+		case item.field == "" && err != nil:
+			t.Errorf("Incorrect error for %s, got %v, expected none", item.user.Username, err)
+		case item.field != "" && (!errors.Is(err, apperr.ErrConflict) || !errors.As(err, &appErr) || len(appErr.Fields) != 1 || appErr.Fields[0].Field != item.field):
+			t.Errorf("Incorrect error for %s, got %v, expected %s to be taken", item.user.Username, err, item.field)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"apirest/models"
+	"apperr"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory implements models.UserStore by keeping users in a map.
+// Data is lost when the process exits, which makes it handy for tests and demos.
+// It never waits, so the contexts received by its methods are not used.
+type Memory struct {
+	mu     sync.RWMutex            // Guards users, nextId and tokens
+	users  map[int64]models.User   // Users indexed by ID
+	nextId int64                   // ID assigned to the next inserted user
+	tokens map[string]models.Token // One-time tokens indexed by ID
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{users: map[int64]models.User{}, nextId: 1, tokens: map[string]models.Token{}}
+}
+
+// ListUsers returns one page of users, applying the filters and sort order
+// of opts, together with the total number of users matching the filters
+func (m *Memory) ListUsers(ctx context.Context, opts models.ListOptions) (models.Users, int, error) {
+	users := m.find(opts)
+
+	// Cut the requested page
+	total := len(users)
+	start := min(opts.Offset(), total)
+	end := min(start+opts.PerPage, total)
+
+	return users[start:end], total, nil
+}
+
+// find returns copies of the users matching the filters of opts, sorted by its sort order
+func (m *Memory) find(opts models.ListOptions) models.Users {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Keep the users matching every filter
+	users := models.Users{}
+	for _, user := range m.users {
+		if user.IsDeleted() == opts.Deleted && matches(&user, opts.Filters) {
+			users = append(users, user)
+		}
+	}
+
+	// Sort them by the requested fields, then by id so the order is stable
+	sortFields := append(append([]models.SortField{}, opts.Sort...), models.SortField{Column: "id"})
+	sort.Slice(users, func(i, j int) bool {
+		for _, field := range sortFields {
+			if c := compare(&users[i], &users[j], field.Column); c != 0 {
+				return (c < 0) != field.Desc
+			}
+		}
+		return false
+	})
+
+	return users
+}
+
+// GetUser returns a copy of the active user with the given ID, or models.ErrNotFound
+func (m *Memory) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok || user.IsDeleted() {
+		return nil, models.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+// GetUserByUsername returns a copy of the active user with the given username, or models.ErrNotFound
+func (m *Memory) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Username == username && !user.IsDeleted() {
+			return &user, nil
+		}
+	}
+
+	return nil, models.ErrNotFound
+}
+
+// GetUserByEmail returns a copy of the active user with the given email, or models.ErrNotFound
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email == email && !user.IsDeleted() {
+			return &user, nil
+		}
+	}
+
+	return nil, models.ErrNotFound
+}
+
+// SaveUser inserts the user when its ID is 0, otherwise replaces the stored copy
+// when it still has the version the user was read with
+func (m *Memory) SaveUser(ctx context.Context, user *models.User) error {
+	// Hash the password and fill in the defaults
+	if err := user.BeforeSave(); err != nil {
+		return apperr.Internal(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Refuse the values of other users, like the unique indexes of the SQL stores
+	if taken := m.taken(user, nil); len(taken) > 0 {
+		return apperr.Duplicate(nil, taken...)
+	}
+
+	if user.Id == 0 {
+		user.Id = m.nextId
+		user.Version = 0
+		m.nextId++
+	} else {
+		// Like the SQL stores, only update active users still at the version the user was read with
+		stored, ok := m.users[user.Id]
+		if !ok || stored.IsDeleted() {
+			return models.ErrNotFound
+		}
+		if stored.Version != user.Version {
+			return models.ErrVersionMismatch
+		}
+		// Keep the creation time and the deletion state
+		user.CreatedAt = stored.CreatedAt
+		user.DeletedAt = stored.DeletedAt
+	}
+	user.Version++
+	m.users[user.Id] = *user
+
+	return nil
+}
+
+// ImportUsers inserts every user at once, so either all of them are saved or none is
+func (m *Memory) ImportUsers(ctx context.Context, users models.Users) error {
+	// Hash the passwords and fill in the defaults before taking the lock
+	for i := range users {
+		if err := users[i].BeforeSave(); err != nil {
+			return apperr.Internal(err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check every user before inserting any, against the stored ones and the ones before it
+	for i := range users {
+		if taken := m.taken(&users[i], users[:i]); len(taken) > 0 {
+			return apperr.Duplicate(nil, taken...)
+		}
+	}
+
+	for i := range users {
+		users[i].Id, users[i].Version = m.nextId, 1
+		m.nextId++
+		m.users[users[i].Id] = users[i]
+	}
+
+	return nil
+}
+
+// taken returns the unique columns of the user, see models.UniqueColumns, whose
+// value another stored user, deleted or not, or one of others already has.
+// The caller must hold the lock.
+func (m *Memory) taken(user *models.User, others models.Users) []string {
+	taken := []string{}
+	for _, column := range models.UniqueColumns {
+		value := user.Field(column)
+		found := false
+		for _, other := range m.users {
+			found = found || other.Id != user.Id && other.Field(column) == value
+		}
+		for _, other := range others {
+			found = found || other.Field(column) == value
+		}
+		if found {
+			taken = append(taken, column)
+		}
+	}
+
+	return taken
+}
+
+// ExportUsers calls fn with a copy of every user matching opts. The store is
+// not locked while fn runs, so slow clients do not block the other requests.
+func (m *Memory) ExportUsers(ctx context.Context, opts models.ListOptions, fn func(models.User) error) error {
+	for _, user := range m.find(opts) {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteUser soft deletes the user with the given user's ID by setting its deletion time,
+// while it is still at the version the user was read with
+func (m *Memory) DeleteUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.Id]
+	if !ok || stored.IsDeleted() {
+		return models.ErrNotFound
+	}
+	if stored.Version != user.Version {
+		return models.ErrVersionMismatch
+	}
+
+	now := models.Now()
+	stored.DeletedAt = &now
+	stored.Version++
+	m.users[user.Id] = stored
+	user.DeletedAt, user.Version = &now, stored.Version
+
+	return nil
+}
+
+// RestoreUser clears the deletion time of the soft deleted user with the given user's ID
+func (m *Memory) RestoreUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.Id]
+	if !ok || !stored.IsDeleted() {
+		return models.ErrNotFound
+	}
+
+	stored.DeletedAt = nil
+	stored.UpdatedAt = models.Now()
+	stored.Version++
+	m.users[user.Id] = stored
+	user.DeletedAt, user.UpdatedAt, user.Version = nil, stored.UpdatedAt, stored.Version
+
+	return nil
+}
+
+// PurgeUser removes the user with the given user's ID
+func (m *Memory) PurgeUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.Id]; !ok {
+		return models.ErrNotFound
+	}
+
+	delete(m.users, user.Id)
+	return nil
+}
+
+// matches reports whether the user satisfies every filter
+func matches(user *models.User, filters []models.Filter) bool {
+	for _, filter := range filters {
+		value := user.Field(filter.Column)
+		if filter.Like && !strings.Contains(strings.ToLower(value), strings.ToLower(filter.Value)) {
+			return false
+		}
+		if !filter.Like && value != filter.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compare orders two users by the given column, comparing ids as numbers
+func compare(a, b *models.User, column string) int {
+	if column == "id" {
+		switch {
+		case a.Id < b.Id:
+			return -1
+		case a.Id > b.Id:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return strings.Compare(a.Field(column), b.Field(column))
+}
@@ -0,0 +1,16 @@
+package store
+
+import "apirest/db"
+
+// NewMySQL returns a store that keeps users in MySQL through the db package.
+// The connection pool must be opened first with db.Open.
+func NewMySQL() *SQLStore {
+	return &SQLStore{
+		exec:  db.ExecContext,
+		query: db.QueryContext,
+		begin: db.BeginTx,
+		close: db.Close, // Closes the connection pool of the db package
+		stats: db.Stats,
+		ping:  db.PingContext,
+	}
+}
@@ -0,0 +1,345 @@
+package store
+
+import (
+	"apirest/metrics"
+	"apirest/models"
+	"apperr"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// userColumns are the columns read by every query, in the order expected by scanUser
+const userColumns = "id, username, password, email, role, created_at, updated_at, deleted_at, email_verified_at, version"
+
+// SQLStore implements models.UserStore with plain SQL statements.
+// The statements only use standard SQL, so the same store works on top of
+// MySQL (through the db package) and SQLite (through its own connection).
+// Database errors are returned as apperr.ErrInternal errors, except for the
+// values refused by the unique indexes, see writeError.
+// The duration of every statement is recorded by the metrics package.
+type SQLStore struct {
+	exec  func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) // Runs a statement
+	query func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)  // Runs a query
+	begin func(ctx context.Context) (*sql.Tx, error)                                       // Starts a transaction
+	close func() error                                                                     // Releases the connection
+	stats func() sql.DBStats                                                               // Reports the state of the connection pool
+	ping  func(ctx context.Context) error                                                  // Checks that the database answers
+}
+
+// insertUser is the statement adding a user, with the arguments of insertArgs
+const insertUser = "INSERT INTO users (username, password, email, role, created_at, updated_at, email_verified_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+// timedExec runs a statement through exec, recording its duration
+func (s *SQLStore) timedExec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer metrics.ObserveQuery(metrics.Operation(query), time.Now())
+	return s.exec(ctx, query, args...)
+}
+
+// timedQuery runs a query through query, recording the time until the first rows are available
+func (s *SQLStore) timedQuery(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer metrics.ObserveQuery(metrics.Operation(query), time.Now())
+	return s.query(ctx, query, args...)
+}
+
+// ListUsers retrieves one page of users, applying the filters and sort order
+// of opts, together with the total number of users matching the filters
+func (s *SQLStore) ListUsers(ctx context.Context, opts models.ListOptions) (models.Users, int, error) {
+	where, args := opts.WhereClause()
+
+	// Count the users matching the filters
+	total := 0
+	rows, err := s.timedQuery(ctx, "SELECT COUNT(*) FROM users"+where, args...)
+	if err != nil {
+		return nil, 0, apperr.Internal(err)
+	}
+	for rows.Next() {
+		if err := rows.Scan(&total); err != nil {
+			rows.Close()
+			return nil, 0, apperr.Internal(err)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, 0, apperr.Internal(err)
+	}
+
+	// Fetch the requested page of users
+	sql := "SELECT " + userColumns + " FROM users" + where + opts.OrderClause() + " LIMIT ? OFFSET ?"
+	users := models.Users{}
+	rows, err = s.timedQuery(ctx, sql, append(args, opts.PerPage, opts.Offset())...)
+	if err != nil {
+		return nil, 0, apperr.Internal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := models.User{}
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, apperr.Internal(err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, apperr.Internal(err)
+	}
+
+	return users, total, nil
+}
+
+// GetUser retrieves a single active user by ID, returning models.ErrNotFound when it does not exist
+func (s *SQLStore) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	return s.getUserWhere(ctx, "id=? AND deleted_at IS NULL", id)
+}
+
+// GetUserByUsername retrieves a single active user by username, returning models.ErrNotFound when it does not exist
+func (s *SQLStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.getUserWhere(ctx, "username=? AND deleted_at IS NULL", username)
+}
+
+// GetUserByEmail retrieves a single active user by email, returning models.ErrNotFound when it does not exist
+func (s *SQLStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.getUserWhere(ctx, "email=? AND deleted_at IS NULL", email)
+}
+
+// getUserWhere retrieves the first user matching the condition
+func (s *SQLStore) getUserWhere(ctx context.Context, condition string, args ...interface{}) (*models.User, error) {
+	sql := "SELECT " + userColumns + " FROM users WHERE " + condition
+	rows, err := s.timedQuery(ctx, sql, args...)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		// A query stopped by an error, e.g. a timeout, did not find the user missing
+		if err := rows.Err(); err != nil {
+			return nil, apperr.Internal(err)
+		}
+		return nil, models.ErrNotFound
+	}
+
+	user := models.NewUser("", "", "")
+	if err := scanUser(rows, user); err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	return user, nil
+}
+
+// scanUser reads the columns listed in userColumns from the current row
+func scanUser(rows *sql.Rows, user *models.User) error {
+	var deletedAt, emailVerifiedAt sql.NullTime
+	if err := rows.Scan(&user.Id, &user.Username, &user.Password, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt, &deletedAt, &emailVerifiedAt, &user.Version); err != nil {
+		return err
+	}
+
+	user.DeletedAt, user.EmailVerifiedAt = nullTime(deletedAt), nullTime(emailVerifiedAt)
+	user.MarkPasswordStored()
+	return nil
+}
+
+// nullTime returns the time of a nullable column, nil for NULL
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Time
+}
+
+// SaveUser inserts the user when its ID is 0, otherwise updates the existing row
+func (s *SQLStore) SaveUser(ctx context.Context, user *models.User) error {
+	// Hash the password and fill in the defaults
+	if err := user.BeforeSave(); err != nil {
+		return apperr.Internal(err)
+	}
+
+	if user.Id == 0 {
+		return s.insert(ctx, user)
+	}
+
+	return s.update(ctx, user)
+}
+
+// insert adds a new row for the user and stores the generated ID on it
+func (s *SQLStore) insert(ctx context.Context, user *models.User) error {
+	result, err := s.timedExec(ctx, insertUser, insertArgs(user)...)
+	if err != nil {
+		return writeError(err)
+	}
+
+	if user.Id, err = result.LastInsertId(); err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// insertArgs returns the arguments of insertUser for a new user, starting at version 1
+func insertArgs(user *models.User) []interface{} {
+	user.Version = 1
+	return []interface{}{user.Username, user.Password, user.Email, user.Role, user.CreatedAt, user.UpdatedAt, user.EmailVerifiedAt, user.Version}
+}
+
+// ImportUsers inserts the users in a single transaction, rolled back when any of them fails
+func (s *SQLStore) ImportUsers(ctx context.Context, users models.Users) error {
+	// Hash the passwords first, so the transaction is not kept open while hashing
+	for i := range users {
+		if err := users[i].BeforeSave(); err != nil {
+			return apperr.Internal(err)
+		}
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	// Undo the inserts unless the transaction is committed
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertUser)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	defer stmt.Close()
+
+	for i := range users {
+		start := time.Now()
+		result, err := stmt.ExecContext(ctx, insertArgs(&users[i])...)
+		metrics.ObserveQuery("insert", start)
+		if err != nil {
+			return writeError(err)
+		}
+		if users[i].Id, err = result.LastInsertId(); err != nil {
+			return apperr.Internal(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// ExportUsers reads the users matching opts row by row and calls fn with each of them
+func (s *SQLStore) ExportUsers(ctx context.Context, opts models.ListOptions, fn func(models.User) error) error {
+	where, args := opts.WhereClause()
+	rows, err := s.timedQuery(ctx, "SELECT "+userColumns+" FROM users"+where+opts.OrderClause(), args...)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := models.User{}
+		if err := scanUser(rows, &user); err != nil {
+			return apperr.Internal(err)
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// update modifies the row of an existing active user, the creation time is never changed.
+// The row is only updated while its version is the one the user was read with.
+func (s *SQLStore) update(ctx context.Context, user *models.User) error {
+	sql := "UPDATE users SET username=?, password=?, email=?, role=?, updated_at=?, email_verified_at=?, version=version+1 WHERE id=? AND version=? AND deleted_at IS NULL"
+	err := s.change(ctx, sql, user.Username, user.Password, user.Email, user.Role, user.UpdatedAt, user.EmailVerifiedAt, user.Id, user.Version)
+	if errors.Is(err, models.ErrNotFound) {
+		// Tell a missing user from one changed by another request
+		if _, err := s.GetUser(ctx, user.Id); err != nil {
+			return err
+		}
+		return models.ErrVersionMismatch
+	}
+	if err != nil {
+		return err
+	}
+
+	user.Version++
+	return nil
+}
+
+// DeleteUser soft deletes the user with the given user's ID by setting its deletion time.
+// Like update, the row is only changed while its version is the one the user was read with.
+func (s *SQLStore) DeleteUser(ctx context.Context, user *models.User) error {
+	now := models.Now()
+	sql := "UPDATE users SET deleted_at=?, version=version+1 WHERE id=? AND version=? AND deleted_at IS NULL"
+	err := s.change(ctx, sql, now, user.Id, user.Version)
+	if errors.Is(err, models.ErrNotFound) {
+		// Tell a missing user from one changed by another request
+		if _, err := s.GetUser(ctx, user.Id); err != nil {
+			return err
+		}
+		return models.ErrVersionMismatch
+	}
+	if err != nil {
+		return err
+	}
+
+	user.DeletedAt = &now
+	user.Version++
+	return nil
+}
+
+// RestoreUser clears the deletion time of the soft deleted user with the given user's ID
+func (s *SQLStore) RestoreUser(ctx context.Context, user *models.User) error {
+	now := models.Now()
+	sql := "UPDATE users SET deleted_at=NULL, updated_at=?, version=version+1 WHERE id=? AND deleted_at IS NOT NULL"
+	if err := s.change(ctx, sql, now, user.Id); err != nil {
+		return err
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = now
+	user.Version++
+	return nil
+}
+
+// PurgeUser removes the row of the user with the given user's ID
+func (s *SQLStore) PurgeUser(ctx context.Context, user *models.User) error {
+	return s.change(ctx, "DELETE FROM users WHERE id=?", user.Id)
+}
+
+// change runs a statement modifying a single user, returning models.ErrNotFound when no row matched
+func (s *SQLStore) change(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.timedExec(ctx, query, args...)
+	if err != nil {
+		return writeError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	if affected == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// Stats returns the state of the connection pool of the store
+func (s *SQLStore) Stats() sql.DBStats {
+	return s.stats()
+}
+
+// PingContext checks that the database of the store answers before the deadline of ctx
+func (s *SQLStore) PingContext(ctx context.Context) error {
+	return s.ping(ctx)
+}
+
+// Close releases the connection used by the store
+func (s *SQLStore) Close() error {
+	return s.close()
+}
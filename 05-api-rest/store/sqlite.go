@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver, no cgo or server required
+)
+
+// sqliteSchema defines the SQL statement to create the "users" table in SQLite
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	email VARCHAR(50),
+	role VARCHAR(10) NOT NULL DEFAULT 'user',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	deleted_at TIMESTAMP NULL,
+	email_verified_at TIMESTAMP NULL,
+	version INTEGER NOT NULL DEFAULT 1)`
+
+// sqliteTokensSchema defines the SQL statement to create the "user_tokens"
+// table in SQLite, holding the one-time tokens sent by email
+const sqliteTokensSchema = `CREATE TABLE IF NOT EXISTS user_tokens (
+	id VARCHAR(64) PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	purpose VARCHAR(20) NOT NULL,
+	email VARCHAR(50) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`
+
+// sqliteUpgrades adds the columns missing from databases created by older
+// versions, in order. SQLite cannot add a column defaulting to the current
+// time, so the times are copied from create_data, the former creation time.
+var sqliteUpgrades = []struct {
+	column     string
+	statements []string
+}{
+	{"role", []string{
+		"ALTER TABLE users ADD COLUMN role VARCHAR(10) NOT NULL DEFAULT 'user'",
+	}},
+	{"created_at", []string{
+		"ALTER TABLE users ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'",
+		"UPDATE users SET created_at = create_data WHERE create_data IS NOT NULL",
+	}},
+	{"updated_at", []string{
+		"ALTER TABLE users ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'",
+		"UPDATE users SET updated_at = created_at",
+	}},
+	{"deleted_at", []string{
+		"ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL",
+	}},
+	{"version", []string{
+		"ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1",
+	}},
+	{"email_verified_at", []string{
+		"ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL",
+	}},
+}
+
+// sqliteIndexes are the indexes created when missing like the MySQL migrations
+// do, starting with the unique indexes of the users table, one for each of
+// models.UniqueColumns. Databases that already hold duplicates cannot be
+// opened until they are fixed.
+var sqliteIndexes = []string{
+	"CREATE UNIQUE INDEX IF NOT EXISTS users_username ON users (username)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users (email)",
+	"CREATE INDEX IF NOT EXISTS user_tokens_user_id ON user_tokens (user_id)",
+	"CREATE INDEX IF NOT EXISTS user_tokens_expires_at ON user_tokens (expires_at)",
+}
+
+// NewSQLite opens (or creates) the SQLite database at path and returns a store
+// on top of it. Use ":memory:" as the path for a throwaway database.
+func NewSQLite(path string) (*SQLStore, error) {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time, and every connection to
+	// ":memory:" would get its own empty database
+	conn.SetMaxOpenConns(1)
+
+	// Create the tables the first time the database is used
+	for _, schema := range []string{sqliteSchema, sqliteTokensSchema} {
+		if _, err := conn.Exec(schema); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := upgradeSQLite(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	begin := func(ctx context.Context) (*sql.Tx, error) { return conn.BeginTx(ctx, nil) }
+	return &SQLStore{exec: conn.ExecContext, query: conn.QueryContext, begin: begin, close: conn.Close, stats: conn.Stats, ping: conn.PingContext}, nil
+}
+
+// upgradeSQLite adds the columns of sqliteUpgrades the users table does not
+// have yet, then the indexes of sqliteIndexes
+func upgradeSQLite(conn *sql.DB) error {
+	rows, err := conn.Query("SELECT name FROM pragma_table_info('users')")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, upgrade := range sqliteUpgrades {
+		if columns[upgrade.column] {
+			continue
+		}
+		for _, statement := range upgrade.statements {
+			if _, err := conn.Exec(statement); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, statement := range sqliteIndexes {
+		if _, err := conn.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
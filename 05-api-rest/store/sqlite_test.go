@@ -0,0 +1,42 @@
+package store
+
+import (
+	"apirest/models"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// TestSQLiteUpgrade checks that a database created before roles, timestamps
+// and versions gets the missing columns on startup, keeping its users
+func TestSQLiteUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, username VARCHAR(30) NOT NULL, password VARCHAR(100) NOT NULL, email VARCHAR(50), create_data TIMESTAMP DEFAULT CURRENT_TIMESTAMP)",
+		"INSERT INTO users (username, password, email) VALUES ('alex', 'password1', 'alex@example.com')",
+	} {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	store, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	user, err := store.GetUser(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alex" || user.Role != models.RoleUser || user.Version != 1 || user.CreatedAt.IsZero() {
+		t.Errorf("Incorrect upgraded user, got %+v, expected alex with role %s and version 1", user, models.RoleUser)
+	}
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"apirest/models"
+	"apperr"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// tokenColumns are the columns of user_tokens read by UseToken, in the order expected by its Scan
+const tokenColumns = "id, user_id, purpose, email, expires_at, used_at, created_at"
+
+// SaveToken inserts the token into user_tokens, after removing the tokens that
+// expired so the table only holds the ones still worth checking
+func (s *SQLStore) SaveToken(ctx context.Context, token *models.Token) error {
+	if _, err := s.timedExec(ctx, "DELETE FROM user_tokens WHERE expires_at <= ?", models.Now()); err != nil {
+		return apperr.Internal(err)
+	}
+
+	sql := "INSERT INTO user_tokens (" + tokenColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?)"
+	if _, err := s.timedExec(ctx, sql, token.Id, token.UserId, token.Purpose, token.Email, token.ExpiresAt, token.UsedAt, token.CreatedAt); err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// UseToken sets the use time of the token, then reads it back. The update only
+// matches an unused and unexpired token, so only one request can use it.
+func (s *SQLStore) UseToken(ctx context.Context, id, purpose string) (*models.Token, error) {
+	now := models.Now()
+	err := s.change(ctx, "UPDATE user_tokens SET used_at=? WHERE id=? AND purpose=? AND used_at IS NULL AND expires_at > ?", now, id, purpose, now)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, models.ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.timedQuery(ctx, "SELECT "+tokenColumns+" FROM user_tokens WHERE id=?", id)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, apperr.Internal(err)
+		}
+		return nil, models.ErrInvalidToken
+	}
+	token := &models.Token{}
+	var usedAt sql.NullTime
+	if err := rows.Scan(&token.Id, &token.UserId, &token.Purpose, &token.Email, &token.ExpiresAt, &usedAt, &token.CreatedAt); err != nil {
+		return nil, apperr.Internal(err)
+	}
+	token.UsedAt = nullTime(usedAt)
+
+	return token, nil
+}
+
+// RevokeTokens sets the use time of every unused token of the user with the given purpose
+func (s *SQLStore) RevokeTokens(ctx context.Context, userId int64, purpose string) error {
+	sql := "UPDATE user_tokens SET used_at=? WHERE user_id=? AND purpose=? AND used_at IS NULL"
+	if _, err := s.timedExec(ctx, sql, models.Now(), userId, purpose); err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// SaveToken stores a copy of the token, after removing the tokens that expired
+func (m *Memory) SaveToken(ctx context.Context, token *models.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := models.Now()
+	for id, stored := range m.tokens {
+		if !stored.ExpiresAt.After(now) {
+			delete(m.tokens, id)
+		}
+	}
+	m.tokens[token.Id] = *token
+
+	return nil
+}
+
+// UseToken sets the use time of the unused and unexpired token and returns a copy of it
+func (m *Memory) UseToken(ctx context.Context, id, purpose string) (*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := models.Now()
+	token, ok := m.tokens[id]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, models.ErrInvalidToken
+	}
+	token.UsedAt = &now
+	m.tokens[id] = token
+
+	return &token, nil
+}
+
+// RevokeTokens sets the use time of every unused token of the user with the given purpose
+func (m *Memory) RevokeTokens(ctx context.Context, userId int64, purpose string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := models.Now()
+	for id, token := range m.tokens {
+		if token.UserId == userId && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+			m.tokens[id] = token
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"apirest/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestTokens checks that the stores only let a token be used once, before it
+// expires and for its own purpose, and that revoked tokens cannot be used
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	sqlite, err := NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	// Define a table of test cases with the stores to check
+	table := []struct {
+		name  string
+		store models.UserStore
+	}{
+		{"memory", NewMemory()},
+		{"sqlite", sqlite},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		alex := models.NewUser("alex", "password1", "alex@example.com")
+		if err := item.store.SaveUser(ctx, alex); err != nil {
+			t.Fatal(err)
+		}
+
+		// Store a token of each kind, an expired one and one revoked by RevokeTokens
+		verify := models.NewToken(alex, "verify_email", time.Hour)
+		reset := models.NewToken(alex, "reset_password", time.Hour)
+		expired := models.NewToken(alex, "reset_password", -time.Hour)
+		for _, token := range []*models.Token{expired, verify, reset} {
+			if err := item.store.SaveToken(ctx, token); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := item.store.RevokeTokens(ctx, alex.Id, "reset_password"); err != nil {
+			t.Fatal(err)
+		}
+		newer := models.NewToken(alex, "reset_password", time.Hour)
+		if err := item.store.SaveToken(ctx, newer); err != nil {
+			t.Fatal(err)
+		}
+
+		// Define the sequence of uses and whether they succeed
+		uses := []struct {
+			token   *models.Token
+			purpose string
+			ok      bool
+		}{
+			{verify, "reset_password", false},
+			{verify, "verify_email", true},
+			{verify, "verify_email", false},
+			{expired, "reset_password", false},
+			{reset, "reset_password", false},
+			{newer, "reset_password", true},
+		}
+		for _, use := range uses {
+			token, err := item.store.UseToken(ctx, use.token.Id, use.purpose)
+			switch {
+			case use.ok && (err != nil || token.UserId != alex.Id || token.Email != alex.Email || token.UsedAt == nil):
+				t.Errorf("%s: incorrect use of %s token, got %+v %v, expected it used", item.name, use.purpose, token, err)
+			case !use.ok && !errors.Is(err, models.ErrInvalidToken):
+				t.Errorf("%s: incorrect use of %s token, got %v, expected %v", item.name, use.purpose, err, models.ErrInvalidToken)
+			}
+		}
+	}
+}
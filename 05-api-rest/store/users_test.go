@@ -0,0 +1,104 @@
+package store
+
+import (
+	"apirest/models"
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestDeleteUserVersion checks that the stores refuse to delete a user changed
+// since it was read, like they refuse to update it
+func TestDeleteUserVersion(t *testing.T) {
+	ctx := context.Background()
+	sqlite, err := NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	// Define a table of test cases with the stores to check
+	table := []struct {
+		name  string
+		store models.UserStore
+	}{
+		{"memory", NewMemory()},
+		{"sqlite", sqlite},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		alex := models.NewUser("alex", "password1", "alex@example.com")
+		if err := item.store.SaveUser(ctx, alex); err != nil {
+			t.Fatal(err)
+		}
+
+		// Another request saves alex after this one read it
+		read := *alex
+		if err := item.store.SaveUser(ctx, alex); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := item.store.DeleteUser(ctx, &read); !errors.Is(err, models.ErrVersionMismatch) {
+			t.Errorf("%s: incorrect error deleting an old version, got %v, expected %v", item.name, err, models.ErrVersionMismatch)
+		}
+		if err := item.store.DeleteUser(ctx, alex); err != nil {
+			t.Errorf("%s: incorrect error deleting the current version, got %v, expected none", item.name, err)
+		}
+		if err := item.store.DeleteUser(ctx, alex); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("%s: incorrect error deleting a deleted user, got %v, expected %v", item.name, err, models.ErrNotFound)
+		}
+	}
+}
+
+// TestSaveUserPassword checks that the stores hash every password sent to
+// them, hashes included, and only keep the hash they read untouched
+func TestSaveUserPassword(t *testing.T) {
+	ctx := context.Background()
+	sqlite, err := NewSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	// A hash a client could send instead of a password
+	chosen, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Define a table of test cases with the stores to check
+	table := []struct {
+		name  string
+		store models.UserStore
+	}{
+		{"memory", NewMemory()},
+		{"sqlite", sqlite},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		alex := models.NewUser("alex", string(chosen), "alex@example.com")
+		if err := item.store.SaveUser(ctx, alex); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := item.store.GetUser(ctx, alex.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Password == string(chosen) || !stored.VerifyPassword(string(chosen)) {
+			t.Errorf("%s: incorrect password, the hash sent was stored as is", item.name)
+		}
+
+		// Saving the user read from the store keeps its hash
+		hash := stored.Password
+		if err := item.store.SaveUser(ctx, stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored, _ = item.store.GetUser(ctx, alex.Id); stored.Password != hash {
+			t.Errorf("%s: incorrect password after saving again, got %s, expected %s", item.name, stored.Password, hash)
+		}
+	}
+}
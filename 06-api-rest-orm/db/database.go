@@ -1,27 +1,107 @@
-package db
-
-import (
-	"fmt"
-
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
-)
-
-// DSN (Data Source Name) string to connect to the MySQL database.
-// It contains the username, password, host, port, database name, and connection options.
-var dsn = "user:pass@tcp(localhost:3306)/goweb_db?charset=utf8mb4&parseTime=True&loc=Local"
-
-// Database is a function that opens a connection to the MySQL database and returns the DB instance.
-// If there's an error during the connection process, it logs the error and panics.
-var Database = func() (db *gorm.DB) {
-	// Attempt to open the MySQL database connection using the DSN.
-	if db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{}); err != nil {
-		// Log the error and panic if the connection fails.
-		fmt.Println("DB connection error", err)
-		panic(err)
-	} else {
-		// Log a success message and return the DB instance if the connection is successful.
-		fmt.Println("DB connection success")
-		return db
-	}
-}()
+package db
+
+import (
+	"config"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Database is the connection to the MySQL database used by the models.
+// It is nil until Open is called.
+var Database *gorm.DB
+
+// ErrNotConnected is returned by Ping before Open is called
+var ErrNotConnected = errors.New("db: not connected, call Open first")
+
+// Open connects to the MySQL database with the given settings and sizes its
+// connection pool. It pings the database, retrying while it is not reachable
+// yet (e.g. when both start together).
+func Open(cfg config.Database) error {
+	// Attempt to open the MySQL database connection using the DSN.
+	// Statements that fail are logged with the ID of their request, see newLogger.
+	db, err := gorm.Open(mysql.Open(cfg.DataSourceName()), &gorm.Config{Logger: newLogger()})
+	if err != nil {
+		// Log the error if the connection fails.
+		slog.Error("DB connection error", "err", err)
+		return err
+	}
+
+	// Time the statements for the metrics
+	if err := registerMetrics(db); err != nil {
+		return err
+	}
+
+	// Size the pool and recycle old connections
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	// Wait for the database to accept connections
+	for attempt := 0; ; attempt++ {
+		if err = sqlDB.Ping(); err == nil {
+			break
+		}
+		if attempt >= cfg.ConnectRetries {
+			sqlDB.Close()
+			return fmt.Errorf("db: cannot reach the database after %d attempts: %w", attempt+1, err)
+		}
+		slog.Warn("Database not ready, retrying", "err", err, "delay", cfg.RetryDelay.String())
+		time.Sleep(cfg.RetryDelay)
+	}
+
+	// Log a success message and keep the DB instance for the models.
+	slog.Info("DB connection success")
+	Database = db
+	return nil
+}
+
+// Ping checks that the database answers before the deadline of ctx, through
+// the connection pool under GORM, e.g. for the readiness probe
+func Ping(ctx context.Context) error {
+	if Database == nil {
+		return ErrNotConnected
+	}
+	sqlDB, err := Database.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// Stats returns the state of the connection pool used by Database,
+// all zeros until Open is called
+func Stats() sql.DBStats {
+	if Database == nil {
+		return sql.DBStats{}
+	}
+	sqlDB, err := Database.DB()
+	if err != nil {
+		return sql.DBStats{}
+	}
+
+	return sqlDB.Stats()
+}
+
+// Close closes the connection pool used by Database.
+// It must be called once no request uses the database anymore.
+func Close() error {
+	sqlDB, err := Database.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
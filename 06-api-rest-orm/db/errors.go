@@ -0,0 +1,35 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// erDupEntry is the number of the MySQL error raised when a statement would
+// store a value already present in a unique index
+const erDupEntry = 1062
+
+// DuplicateKey reports whether err was raised by a unique index and returns the
+// name of the index, e.g. "users_email" for "Duplicate entry 'kim@example.com'
+// for key 'users.users_email'". GORM translates the error to
+// gorm.ErrDuplicatedKey when TranslateError is on, the index is "" then.
+func DuplicateKey(err error) (string, bool) {
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &mysqlErr) && mysqlErr.Number == erDupEntry:
+		// The duplicate value comes first and could contain anything, so the key is after the last " for key "
+		i := strings.LastIndex(mysqlErr.Message, " for key ")
+		if i < 0 {
+			return "", true
+		}
+		key := strings.Trim(mysqlErr.Message[i+len(" for key "):], "'` ")
+		return key[strings.LastIndex(key, ".")+1:], true
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return "", true
+	default:
+		return "", false
+	}
+}
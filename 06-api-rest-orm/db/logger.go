@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"logging"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQuery is the duration after which a statement is logged as slow
+const slowQuery = 200 * time.Millisecond
+
+// slogLogger sends the logs of GORM to log/slog. The records carry the ID of
+// the request that ran the statement, taken from the context given to
+// Database.WithContext, so an SQL error can be found next to its request.
+type slogLogger struct {
+	level logger.LogLevel // Records less severe than this are skipped
+}
+
+// LogMode returns a copy of the logger keeping the records of the given level
+func (l slogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return slogLogger{level: level}
+}
+
+// Info logs a message of GORM at the info level
+func (l slogLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		logging.FromContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Warn logs a message of GORM at the warning level
+func (l slogLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		logging.FromContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Error logs a message of GORM at the error level
+func (l slogLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		logging.FromContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace logs the statements that failed and the slow ones. Missing records
+// are not errors, the models report them as ErrNotFound.
+func (l slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logging.FromContext(ctx).Error("SQL statement failed", "query", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds(), "err", err)
+	case elapsed > slowQuery && l.level >= logger.Warn:
+		sql, rows := fc()
+		logging.FromContext(ctx).Warn("Slow SQL statement", "query", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	}
+}
+
+// newLogger returns the logger of the connection, logging errors and slow statements
+func newLogger() logger.Interface {
+	return slogLogger{level: logger.Warn}
+}
+
+// Make sure slogLogger implements the logger of GORM
+var _ logger.Interface = slogLogger{}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"errors"
+	"gorm/metrics"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// startKey is the setting of a statement holding the time it started at
+const startKey = "metrics:start"
+
+// registerMetrics adds callbacks around every type of statement of GORM,
+// recording their duration in the metrics labeled with the type of SQL
+// statement, as metrics.Operation names them: "select" for the finds of the
+// models, "insert", "update", "delete", and the type of the statement itself
+// for Raw and Row. The hooks of the models, such as hashing the password
+// before saving, are not part of the duration.
+func registerMetrics(db *gorm.DB) error {
+	// Remember when the statement starts
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(startKey, time.Now())
+	}
+	// Record its duration once it is done. An empty operation is taken from
+	// the SQL of the statement.
+	after := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			if start, ok := tx.InstanceGet(startKey); ok {
+				label := operation
+				if label == "" {
+					label = metrics.Operation(tx.Statement.SQL.String())
+				}
+				metrics.ObserveQuery(label, start.(time.Time))
+			}
+		}
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", before),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", after("insert")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", before),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", after("select")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", before),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", before),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", after("")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", after("")),
+	)
+}
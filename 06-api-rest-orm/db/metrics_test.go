@@ -0,0 +1,68 @@
+package db
+
+import (
+	"gorm/metrics"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestRegisterMetrics checks that the statements of GORM are labeled like those of 05.
+func TestRegisterMetrics(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registerMetrics(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// note is a model of its own, so the test does not depend on the users
+	type note struct {
+		ID   uint
+		Text string
+	}
+	if err := db.AutoMigrate(&note{}); err != nil {
+		t.Fatal(err)
+	}
+	item := note{Text: "first"}
+	count := 0
+	for _, err := range []error{
+		db.Create(&item).Error,
+		db.First(&item).Error,
+		db.Model(&item).Update("text", "second").Error,
+		db.Raw("SELECT COUNT(*) FROM notes").Scan(&count).Error,
+		db.Table("notes").Select("COUNT(*)").Row().Scan(&count),
+		db.Exec("DELETE FROM notes WHERE id = ?", 0).Error,
+		db.Delete(&item).Error,
+		db.Exec("VACUUM").Error,
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rw, httptest.NewRequest("GET", metrics.Path, nil))
+
+	// Define a table of test cases with the labels expected in the metrics,
+	// AutoMigrate running statements of its own
+	table := []string{"select", "insert", "update", "delete", "other"}
+
+	// Loop through each test case
+	for _, label := range table {
+		series := `db_query_duration_seconds_count{operation="` + label + `"}`
+		if !strings.Contains(rw.Body.String(), series) {
+			t.Errorf("Incorrect metrics, %s is missing", series)
+		}
+	}
+	for _, label := range []string{"create", "query", "row", "raw"} {
+		if strings.Contains(rw.Body.String(), `operation="`+label+`"`) {
+			t.Errorf("Incorrect metrics, got the GORM label %q", label)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"apperr"
+	"auth"
+	"context"
+	"errors"
+	"fmt"
+	"gorm/models"
+	"logging"
+	"mail"
+	"net/http"
+	"strconv"
+	"time"
+	"validation"
+
+	"github.com/gorilla/mux"
+)
+
+// Lifetimes of the tokens sent by email
+const (
+	VerifyEmailTTL   = 24 * time.Hour // Verifying an email address
+	ResetPasswordTTL = time.Hour      // Choosing a new password
+)
+
+// verifyEmailRequest holds the body of an email verification request
+type verifyEmailRequest struct {
+	Token string `json:"token"` // Token received by email, empty to ask for a new one
+}
+
+// forgotPasswordRequest holds the body of a forgotten password request
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=50"`
+}
+
+// resetPasswordRequest holds the body of a password reset request
+type resetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// Accounts sends the users the one-time tokens verifying their email address
+// or resetting their password: tokens signed by the TokenManager whose ID is
+// kept in the user_tokens table, see models.Token.
+type Accounts struct {
+	tokens *auth.TokenManager // Signs the tokens sent by email
+	mailer mail.Mailer        // Sends the emails
+}
+
+// NewAccounts creates the Accounts sending its emails with the given mailer
+func NewAccounts(tokens *auth.TokenManager, mailer mail.Mailer) *Accounts {
+	return &Accounts{tokens: tokens, mailer: mailer}
+}
+
+// SendVerification emails the user a token proving they own their address,
+// valid for VerifyEmailTTL. The tokens sent before are revoked.
+func (a *Accounts) SendVerification(ctx context.Context, user models.User) error {
+	token, err := a.issue(ctx, user, auth.VerifyEmailToken, VerifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Confirm that %s is your email address by sending this token before %s:\n\n"+
+		"POST /api/user/%d/verify-email\n{\"token\": %q}\n\n"+
+		"If you did not sign up, ignore this email.\n",
+		user.Username, user.Email, time.Now().Add(VerifyEmailTTL).UTC().Format(time.RFC1123), user.Id, token)
+	return a.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Verify your email address", Body: body})
+}
+
+// sendReset emails the user a token letting them choose a new password,
+// valid for ResetPasswordTTL. The tokens sent before are revoked.
+func (a *Accounts) sendReset(ctx context.Context, user models.User) error {
+	token, err := a.issue(ctx, user, auth.ResetPasswordToken, ResetPasswordTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Choose a new password by sending this token before %s:\n\n"+
+		"POST /api/password/reset\n{\"token\": %q, \"password\": \"<new password>\"}\n\n"+
+		"If you did not ask for it, ignore this email, your password is unchanged.\n",
+		user.Username, time.Now().Add(ResetPasswordTTL).UTC().Format(time.RFC1123), token)
+	return a.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Reset your password", Body: body})
+}
+
+// issue revokes the unused tokens of the user with the given purpose, then
+// saves a new one and returns it signed
+func (a *Accounts) issue(ctx context.Context, user models.User, purpose string, ttl time.Duration) (string, error) {
+	if err := models.RevokeTokens(ctx, user.Id, purpose); err != nil {
+		return "", err
+	}
+
+	token := models.NewToken(user, purpose, ttl)
+	signed, err := a.tokens.IssueOneTime(user.Id, purpose, token.Id, ttl)
+	if err != nil {
+		return "", apperr.Internal(err)
+	}
+	if err := token.Save(ctx); err != nil {
+		return "", err
+	}
+
+	return signed, nil
+}
+
+// use checks the signature, kind and expiry of a token received by email, and
+// that it was issued for the user with the given ID unless it is 0, then marks
+// it as used. It returns the saved token, or models.ErrInvalidToken when the
+// token cannot be used.
+func (a *Accounts) use(ctx context.Context, signed, purpose string, userId int64) (models.Token, error) {
+	claims, err := a.tokens.Parse(signed, purpose)
+	if err != nil || claims.ID == "" || userId != 0 && claims.UserId() != userId {
+		// Tokens of other users are refused before they are used, so they still work for their owner.
+		return models.Token{}, models.ErrInvalidToken
+	}
+
+	token, err := models.UseToken(ctx, claims.ID, purpose)
+	if err != nil {
+		return models.Token{}, err
+	}
+	if token.UserId != claims.UserId() {
+		return models.Token{}, models.ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// VerifyEmail returns the handler verifying the email address of a user by their ID.
+// With the token received by email in the body, it marks the address as
+// verified and sends the user. Without a token, the user themselves or an
+// admin gets a new token sent to the address, with a 202 Accepted status.
+func VerifyEmail(accounts *Accounts) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Decode the token from the request body, rejecting unknown fields.
+		body := verifyEmailRequest{}
+		if errs := validation.DecodeJSON(r.Body, &body); len(errs) > 0 {
+			// If decoding fails, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+		userId, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+		if body.Token == "" {
+			resendVerification(rw, r, accounts, userId)
+			return
+		}
+
+		// Use the token, it must have been sent to the current address of the user of the route.
+		token, err := accounts.use(r.Context(), body.Token, auth.VerifyEmailToken, userId)
+		if err != nil {
+			sendError(rw, r, err)
+			return
+		}
+		user, err := models.FindUser(r.Context(), userId)
+		if err == nil && user.Email != token.Email {
+			// The address changed since the token was sent.
+			err = models.ErrInvalidToken
+		}
+		if err != nil {
+			sendError(rw, r, err)
+			return
+		}
+
+		// Mark the address as verified, verifying it again keeps the first time.
+		if !user.IsEmailVerified() {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if err := user.Save(r.Context()); err != nil {
+				sendError(rw, r, err)
+				return
+			}
+		}
+		sendUser(rw, user, http.StatusOK)
+	}
+}
+
+// resendVerification sends a new verification token to the user with the
+// given ID, when the request comes from the user themselves or an admin
+func resendVerification(rw http.ResponseWriter, r *http.Request, accounts *Accounts, userId int64) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		// Without a token nor a bearer token, send an error response with status 401 Unauthorized.
+		sendError(rw, r, apperr.Unauthorized("Send the token received by email, or authenticate to get a new one"))
+		return
+	}
+	if !identity.IsAdmin() && identity.Id != userId {
+		// If the user is someone else, send an error response with status 403 Forbidden.
+		sendError(rw, r, apperr.Forbidden())
+		return
+	}
+
+	user, err := models.FindUser(r.Context(), userId)
+	if err == nil && user.IsEmailVerified() {
+		err = apperr.Conflict("The email address is already verified")
+	}
+	if err == nil {
+		err = accounts.SendVerification(r.Context(), user)
+	}
+	if err != nil {
+		sendError(rw, r, err)
+		return
+	}
+
+	sendMessage(rw, "A verification email was sent to "+user.Email, http.StatusAccepted)
+}
+
+// ForgotPassword returns the handler asking for a password reset.
+// It emails a token to the active user with the given address, if there is
+// one. The response is the same either way, so it does not reveal which
+// addresses belong to users.
+func ForgotPassword(accounts *Accounts) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Decode the email address from the request body, rejecting unknown fields.
+		body := forgotPasswordRequest{}
+		errs := validation.DecodeJSON(r.Body, &body)
+		if len(errs) == 0 {
+			errs = validation.Struct(&body)
+		}
+		if len(errs) > 0 {
+			// If the body is not valid, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+
+		user, err := models.FindUserByEmail(r.Context(), body.Email)
+		if err == nil {
+			err = accounts.sendReset(r.Context(), user)
+		}
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			// Only the logs tell the email could not be sent.
+			logging.FromContext(r.Context()).Error("password reset email failed", "err", err)
+		}
+
+		sendMessage(rw, "If the address belongs to a user, a password reset email was sent to it", http.StatusAccepted)
+	}
+}
+
+// ResetPassword returns the handler choosing a new password with the token
+// received by email. The token also proves the user owns their address, so
+// it is marked as verified. It sends the user with their new version.
+func ResetPassword(accounts *Accounts) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Decode the token and the new password from the request body, rejecting unknown fields.
+		body := resetPasswordRequest{}
+		errs := validation.DecodeJSON(r.Body, &body)
+		if len(errs) == 0 {
+			errs = validation.Struct(&body)
+		}
+		if len(errs) > 0 {
+			// If the body is not valid, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+
+		// Use the token, it must have been sent to the current address of its user.
+		token, err := accounts.use(r.Context(), body.Token, auth.ResetPasswordToken, 0)
+		if err != nil {
+			sendError(rw, r, err)
+			return
+		}
+		user, err := models.FindUser(r.Context(), token.UserId)
+		if errors.Is(err, models.ErrNotFound) || err == nil && user.Email != token.Email {
+			// The user was deleted or their address changed since the token was sent.
+			err = models.ErrInvalidToken
+		}
+		if err != nil {
+			sendError(rw, r, err)
+			return
+		}
+
+		// Save the new password, it is hashed before it is written.
+		user.Password = body.Password
+		if !user.IsEmailVerified() {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		if err := user.Save(r.Context()); err != nil {
+			sendError(rw, r, err)
+			return
+		}
+		sendUser(rw, user, http.StatusOK)
+	}
+}
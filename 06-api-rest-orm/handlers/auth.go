@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"apperr"
+	"auth"
+	"errors"
+	"gorm/models"
+	"logging"
+	"net/http"
+	"validation"
+)
+
+// credentials holds the body of a login request
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// refreshRequest holds the body of a token refresh request
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Login returns the handler that authenticates a user.
+// It checks the username and password against the users table and sends a
+// pair of access and refresh tokens signed by the given TokenManager when they match.
+func Login(tokens *auth.TokenManager) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Decode the credentials from the request body.
+		creds := credentials{}
+		if errs := validation.DecodeJSON(r.Body, &creds); len(errs) > 0 {
+			// If decoding fails, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+
+		// Look up the user and verify the password. Both failures get the same
+		// answer so the response does not reveal which usernames exist.
+		user, err := models.FindUserByUsername(r.Context(), creds.Username)
+		if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			// If the database fails, send an error response with status 500.
+			sendError(rw, r, err)
+			return
+		}
+		if err != nil || !user.VerifyPassword(creds.Password) {
+			sendError(rw, r, apperr.Unauthorized("Invalid username or password"))
+			return
+		}
+
+		// Upgrade plaintext or outdated hashes now that we know the plaintext.
+		// The user is logged in either way, the hash is upgraded on a later login.
+		if user.NeedsRehash() {
+			user.Password = creds.Password
+			if err := user.Save(r.Context()); err != nil {
+				logging.FromContext(r.Context()).Error("password rehash failed", "user_id", user.Id, "err", err)
+			}
+		}
+
+		sendTokens(rw, r, tokens, user)
+	}
+}
+
+// Refresh returns the handler that exchanges a refresh token for a new pair of tokens.
+func Refresh(tokens *auth.TokenManager) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Decode the refresh token from the request body.
+		body := refreshRequest{}
+		if errs := validation.DecodeJSON(r.Body, &body); len(errs) > 0 {
+			// If decoding fails, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+
+		// Verify the token, only refresh tokens are accepted here.
+		claims, err := tokens.Parse(body.RefreshToken, auth.RefreshToken)
+		if err != nil {
+			sendError(rw, r, apperr.Unauthorized("Invalid or expired refresh token"))
+			return
+		}
+
+		// Make sure the user still exists before issuing new tokens.
+		user, err := models.FindUser(r.Context(), claims.UserId())
+		if err != nil {
+			sendError(rw, r, apperr.Unauthorized("Invalid or expired refresh token"))
+			return
+		}
+
+		sendTokens(rw, r, tokens, user)
+	}
+}
+
+// sendTokens issues a new pair of tokens for the user and sends it in the response.
+func sendTokens(rw http.ResponseWriter, r *http.Request, tokens *auth.TokenManager, user models.User) {
+	if pair, err := tokens.Issue(user.Id, user.Username, user.Role); err != nil {
+		// If the tokens cannot be signed, send an error response with status 500.
+		sendError(rw, r, apperr.Internal(err))
+	} else {
+		// Send the tokens in the response with a 200 OK status.
+		sendData(rw, pair, http.StatusOK)
+	}
+}
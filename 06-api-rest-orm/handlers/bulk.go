@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"apperr"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm/models"
+	"io"
+	"logging"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"validation"
+)
+
+// maxLineSize is the longest NDJSON line accepted by an import
+const maxLineSize = 64 * 1024
+
+// rowReader reads the users of an import body one row at a time
+type rowReader interface {
+	// next returns the user of the next row and its line. The user could not be
+	// read when errs is not empty, err is io.EOF after the last row and any
+	// other err means the rest of the body cannot be read.
+	next() (user models.User, line int, errs validation.Errors, err error)
+}
+
+// ImportUsers handles the request to create many users at once.
+// The body is streamed as CSV (Content-Type: text/csv) with a header line naming
+// the columns, or as NDJSON (Content-Type: application/x-ndjson) with one user
+// per line. Every row is validated like in CreateUser, the valid ones are saved
+// in transactions of models.ImportBatchSize users and the response reports how
+// many users were imported together with the problems of every failed row.
+func ImportUsers(rw http.ResponseWriter, r *http.Request) {
+	// Choose how to read the rows from the content type of the body.
+	rows, err := newRowReader(r)
+	if err != nil {
+		sendError(rw, r, err)
+		return
+	}
+
+	report := models.NewImportReport()
+	batch, lines := models.Users{}, []int{}
+	taken := map[string]bool{} // Usernames and emails of the rows waiting to be saved
+
+	// save inserts the pending rows in a single transaction.
+	save := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := models.ImportUsers(r.Context(), batch); err != nil {
+			// None of the rows of the batch was saved, report every one of them.
+			logging.FromContext(r.Context()).Error("import failed", "users", len(batch), "err", err)
+			for _, line := range lines {
+				report.Fail(line, rowError("could not be saved"))
+			}
+		} else {
+			report.Imported += len(batch)
+		}
+		batch, lines = models.Users{}, []int{}
+	}
+
+	// Read the rows until the end of the body, or until the request is canceled.
+	for r.Context().Err() == nil {
+		user, line, errs, err := rows.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			// The body is broken, save what was read so far and stop.
+			report.Fail(line, validation.Errors{{Field: "body", Reason: err.Error()}})
+			break
+		}
+
+		if len(errs) == 0 {
+			errs = validateRow(r, &user, taken)
+		}
+		if len(errs) > 0 {
+			report.Fail(line, errs)
+			continue
+		}
+
+		// Keep the row for the next transaction, its username and email are no longer free.
+		taken["username:"+user.Username], taken["email:"+user.Email] = true, true
+		batch, lines = append(batch, user), append(lines, line)
+		if len(batch) >= models.ImportBatchSize {
+			save()
+		}
+	}
+	save()
+
+	// Send how many users were imported and why the other rows were not.
+	sendData(rw, report, http.StatusOK)
+}
+
+// validateRow prepares a user read by ImportUsers like CreateUser does and
+// returns its invalid fields. Usernames and emails are also checked against
+// the rows of the import that are not saved yet.
+func validateRow(r *http.Request, user *models.User, taken map[string]bool) validation.Errors {
+	// The ID, version and timestamps are set by GORM.
+	id := user.Id
+	*user = models.User{Username: user.Username, Password: user.Password, Email: user.Email, Role: user.Role}
+	if id != 0 {
+		return validation.Errors{{Field: "id", Reason: "is not allowed"}}
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
+	errs := validation.Errors{}
+	if err := user.Validate(r.Context()); err != nil {
+		// Invalid and taken fields are both problems of the row.
+		var appErr *apperr.Error
+		if !errors.Is(err, apperr.ErrValidation) && !errors.Is(err, apperr.ErrConflict) || !errors.As(err, &appErr) {
+			// The uniqueness checks failed, the row cannot be checked.
+			logging.FromContext(r.Context()).Error("import row check failed", "err", err)
+			return rowError("could not be checked")
+		}
+		errs = appErr.Fields
+	}
+	if taken["username:"+user.Username] {
+		errs.Add("username", "is already taken")
+	}
+	if taken["email:"+user.Email] {
+		errs.Add("email", "is already taken")
+	}
+
+	return errs
+}
+
+// rowError returns the errors of a row that failed as a whole
+func rowError(reason string) validation.Errors {
+	return validation.Errors{{Field: "row", Reason: reason}}
+}
+
+// newRowReader returns the reader matching the content type of the request.
+// CSV bodies must start with a header line naming the columns.
+func newRowReader(r *http.Request) (rowReader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return newCSVReader(r.Body)
+	case "application/x-ndjson", "application/ndjson":
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	default:
+		return nil, apperr.UnsupportedMediaType("The body must be text/csv or application/x-ndjson")
+	}
+}
+
+// csvReader reads users from CSV records, mapping the fields with the header line
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string // Name of every column, from the header line
+	line    int      // Line of the last record read
+}
+
+// newCSVReader reads the header line and checks that it names known columns
+func newCSVReader(body io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, apperr.BadRequest("The body must start with a CSV header line")
+	}
+
+	columns := []string{}
+	for _, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(models.ImportColumns, column) {
+			return nil, apperr.BadRequest(fmt.Sprintf("Unknown column %q, the columns are %s", column, strings.Join(models.ImportColumns, ", ")))
+		}
+		columns = append(columns, column)
+	}
+	for _, column := range []string{"username", "password", "email"} {
+		if !slices.Contains(columns, column) {
+			return nil, apperr.BadRequest(fmt.Sprintf("Missing column %q", column))
+		}
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+// next reads the next record. Records with the wrong number of fields are
+// reported as row errors, any other problem stops the import.
+func (c *csvReader) next() (models.User, int, validation.Errors, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		// Errors of the CSV syntax tell their line, others happen after the last record
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			c.line = parseErr.StartLine
+		} else {
+			c.line++
+		}
+		if errors.Is(err, csv.ErrFieldCount) {
+			return models.User{}, c.line, rowError(fmt.Sprintf("must have %d fields", len(c.columns))), nil
+		}
+		return models.User{}, c.line, nil, err
+	}
+	c.line, _ = c.reader.FieldPos(0)
+
+	// Passwords are kept as they are, spaces included
+	user := models.User{}
+	for i, column := range c.columns {
+		value := strings.TrimSpace(record[i])
+		switch column {
+		case "username":
+			user.Username = value
+		case "password":
+			user.Password = record[i]
+		case "email":
+			user.Email = value
+		case "role":
+			user.Role = value
+		}
+	}
+
+	return user, c.line, nil, nil
+}
+
+// ndjsonReader reads users from lines holding a JSON object each, blank lines are skipped
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int // Line of the last row read
+}
+
+// next decodes the next line, rejecting unknown fields like CreateUser does
+func (n *ndjsonReader) next() (models.User, int, validation.Errors, error) {
+	for n.scanner.Scan() {
+		n.line++
+		text := strings.TrimSpace(n.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		user := models.User{}
+		errs := validation.DecodeJSON(strings.NewReader(text), &user)
+		return user, n.line, errs, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return models.User{}, n.line + 1, nil, err
+	}
+
+	return models.User{}, n.line, nil, io.EOF
+}
+
+// rowWriter writes the users of an export one at a time
+type rowWriter interface {
+	write(user models.User) error // Writes one user
+	finish() error                // Writes what is still buffered
+}
+
+// ExportUsers handles the request to download every active user, or every soft
+// deleted user with ?deleted=true, sorted by ID. ?format=csv sends CSV with a
+// header line, ?format=ndjson (the default) one JSON object per line. The users
+// are streamed as they are read, so the response can be as large as the table.
+// Passwords are never exported.
+func ExportUsers(rw http.ResponseWriter, r *http.Request) {
+	deleted, _ := strconv.ParseBool(r.URL.Query().Get("deleted"))
+
+	// Choose the format of the response.
+	var rows rowWriter
+	switch r.URL.Query().Get("format") {
+	case models.FormatCSV:
+		rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		rw.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+		rows = &csvWriter{writer: csv.NewWriter(rw)}
+	case models.FormatNDJSON, "":
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+		rw.Header().Set("Content-Disposition", `attachment; filename="users.ndjson"`)
+		rows = &ndjsonWriter{encoder: json.NewEncoder(rw)}
+	default:
+		sendError(rw, r, apperr.BadRequest("format must be csv or ndjson"))
+		return
+	}
+
+	// Send the users as they are read from the database.
+	written := false
+	err := models.ExportUsers(r.Context(), deleted, func(user models.User) error {
+		written = true
+		return rows.write(user)
+	})
+	if err == nil {
+		err = rows.finish()
+	}
+
+	if err != nil && !written {
+		// Nothing was sent yet, send an error response.
+		rw.Header().Del("Content-Type")
+		rw.Header().Del("Content-Disposition")
+		sendError(rw, r, err)
+	} else if err != nil {
+		// Part of the users was already sent. Abort the response so the
+		// client sees it is incomplete instead of a shorter list.
+		logging.FromContext(r.Context()).Error("export failed", "err", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// csvWriter writes users as CSV records, after a header line
+type csvWriter struct {
+	writer *csv.Writer
+	header bool // Whether the header line was written
+}
+
+// write writes the header line before the first user, then the user
+func (c *csvWriter) write(user models.User) error {
+	if !c.header {
+		c.header = true
+		if err := c.writer.Write(models.ExportColumns); err != nil {
+			return err
+		}
+	}
+
+	return c.writer.Write(user.Record())
+}
+
+// finish writes the header line when there was no user, then flushes the buffer
+func (c *csvWriter) finish() error {
+	if !c.header {
+		c.header = true
+		c.writer.Write(models.ExportColumns)
+	}
+	c.writer.Flush()
+
+	return c.writer.Error()
+}
+
+// ndjsonWriter writes users as JSON objects, one per line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+// write writes the user followed by a newline
+func (n *ndjsonWriter) write(user models.User) error {
+	return n.encoder.Encode(user)
+}
+
+// finish has nothing to do, every user is written right away
+func (n *ndjsonWriter) finish() error {
+	return nil
+}
@@ -1,119 +1,329 @@
-package handlers
-
-import (
-	"encoding/json"
-	"gorm/db"
-	"gorm/models"
-	"net/http"
-	"strconv"
-
-	"github.com/gorilla/mux"
-	"gorm.io/gorm"
-)
-
-// GetUsers handles the request to list all users from the database.
-// It fetches the list of users, and if successful, sends the list of users in the response with a 200 OK status.
-// If an error occurs, it sends a "Not Found" response.
-func GetUsers(rw http.ResponseWriter, r *http.Request) {
-	users := models.Users{}
-	// Fetch all users from the database.
-	db.Database.Find(&users)
-	// Send the list of users in the response.
-	sendData(rw, users, http.StatusOK)
-}
-
-// GetUser handles the request to fetch a single user by their ID.
-// It extracts the user ID from the request, fetches the user, and sends the user data in the response.
-// If the user is not found, it sends a "Not Found" response.
-func GetUser(rw http.ResponseWriter, r *http.Request) {
-	// Try to retrieve the user by their ID from the request.
-	if user, err := getUserByID(r); err != nil {
-		// If user not found, send an error response.
-		sendError(rw, http.StatusNotFound)
-	} else {
-		// Send the found user data in the response.
-		sendData(rw, user, http.StatusOK)
-	}
-}
-
-// getUserByID extracts the user ID from the request and retrieves the corresponding user from the database.
-// It returns the user and any error encountered during the retrieval process.
-func getUserByID(r *http.Request) (models.User, *gorm.DB) {
-	vars := mux.Vars(r)
-	// Extract user ID from the URL path parameter.
-	userId, _ := strconv.Atoi(vars["id"])
-
-	user := models.User{}
-	// Attempt to fetch the user by the ID.
-	if err := db.Database.First(&user, userId); err.Error != nil {
-		// Return the error if the user is not found.
-		return user, err
-	} else {
-		// Return the user and nil error if the user is found.
-		return user, nil
-	}
-}
-
-// CreateUser handles the request to create a new user.
-// It decodes the user data from the request body, saves the user to the database,
-// and sends the newly created user data in the response with a 201 Created status.
-func CreateUser(rw http.ResponseWriter, r *http.Request) {
-	// Create an empty User object to hold the incoming data.
-	user := models.User{}
-	// Decode the incoming JSON request body into the User object.
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&user); err != nil {
-		// If decoding fails, send an error response with status 422 Unprocessable Entity.
-		sendError(rw, http.StatusUnprocessableEntity)
-	} else {
-		// Save the new user to the database.
-		db.Database.Save(&user)
-		// Send the newly created user in the response with a 201 Created status.
-		sendData(rw, user, http.StatusCreated)
-	}
-}
-
-// DeleteUser handles the request to delete a user by their ID.
-// It attempts to fetch the user from the database, deletes it, and sends the deleted user's data in the response.
-// If the user is not found, it sends a "Not Found" response.
-func DeleteUser(rw http.ResponseWriter, r *http.Request) {
-	// Attempt to retrieve the user by ID from the request.
-	if user, err := getUserByID(r); err != nil {
-		// If user not found, send an error response.
-		sendError(rw, http.StatusNotFound)
-	} else {
-		// Delete the user from the database.
-		db.Database.Delete(&user)
-		// Send the deleted user data in the response.
-		sendData(rw, user, http.StatusOK)
-	}
-}
-
-// UpdateUser handles the request to update an existing user's data.
-// It retrieves the user by their ID, decodes the new user data from the request body,
-// updates the user in the database, and sends the updated user data in the response.
-func UpdateUser(rw http.ResponseWriter, r *http.Request) {
-	var userId int64
-	// Try to retrieve the current user by ID from the request.
-	if user_ant, err := getUserByID(r); err != nil {
-		// If user not found, send an error response.
-		sendError(rw, http.StatusNotFound)
-	} else {
-		// Save the current user's ID to update the correct user.
-		userId = user_ant.Id
-		user := models.User{}
-		// Decode the new user data from the request body.
-		decoder := json.NewDecoder(r.Body)
-		if err := decoder.Decode(&user); err != nil {
-			// If decoding fails, send an error response with status 422 Unprocessable Entity.
-			sendError(rw, http.StatusUnprocessableEntity)
-		} else {
-			// Assign the original user ID to the updated user to avoid overwriting it.
-			user.Id = userId
-			// Save the updated user to the database.
-			db.Database.Save(&user)
-			// Send the updated user data in the response.
-			sendData(rw, user, http.StatusOK)
-		}
-	}
-}
+package handlers
+
+import (
+	"apperr"
+	"auth"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"gorm/models"
+	"io"
+	"logging"
+	"net/http"
+	"patch"
+	"strconv"
+	"time"
+	"validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetUsers handles the request to list all users from the database.
+// It fetches the list of users, and if successful, sends the list of users in the response with a 200 OK status.
+// With ?deleted=true it lists the soft deleted users instead. If an error occurs, it sends an error response.
+func GetUsers(rw http.ResponseWriter, r *http.Request) {
+	list := models.AllUsers
+	if deleted, _ := strconv.ParseBool(r.URL.Query().Get("deleted")); deleted {
+		list = models.DeletedUsers
+	}
+
+	// Fetch the users from the database.
+	if users, err := list(r.Context()); err != nil {
+		// If the users cannot be fetched, send an error response.
+		sendError(rw, r, err)
+	} else {
+		// Send the list of users in the response.
+		sendData(rw, users, http.StatusOK)
+	}
+}
+
+// GetUser handles the request to fetch a single user by their ID.
+// It extracts the user ID from the request, fetches the user, and sends the user data in the response.
+// If the user is not found, it sends a "Not Found" response.
+func GetUser(rw http.ResponseWriter, r *http.Request) {
+	// Try to retrieve the user by their ID from the request.
+	if user, err := getUserByID(r); err != nil {
+		// If user not found, send an error response.
+		sendError(rw, r, err)
+	} else {
+		// Send the found user data in the response.
+		sendUser(rw, user, http.StatusOK)
+	}
+}
+
+// getUserByID extracts the user ID from the request and retrieves the corresponding user from the database.
+// It returns the user and any error encountered during the retrieval process.
+func getUserByID(r *http.Request) (models.User, error) {
+	vars := mux.Vars(r)
+	// Extract user ID from the URL path parameter.
+	userId, _ := strconv.ParseInt(vars["id"], 10, 64)
+
+	// Attempt to fetch the user by the ID, models.ErrNotFound if it does not exist.
+	return models.FindUser(r.Context(), userId)
+}
+
+// getUserToChange retrieves the user like getUserByID, then checks the If-Match
+// header of the request against the ETag of the user. When the user cannot be
+// changed, it sends an error response and returns false.
+func getUserToChange(rw http.ResponseWriter, r *http.Request) (models.User, bool) {
+	user, err := getUserByID(r)
+	if err == nil && !user.MatchesETag(r.Header.Get("If-Match")) {
+		// The client edited an older version of the user.
+		err = models.ErrVersionMismatch
+	}
+	if err != nil {
+		sendError(rw, r, err)
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// getDeletedUserByID is like getUserByID, but only finds soft deleted users
+func getDeletedUserByID(r *http.Request) (models.User, error) {
+	vars := mux.Vars(r)
+	userId, _ := strconv.ParseInt(vars["id"], 10, 64)
+
+	return models.FindDeletedUser(r.Context(), userId)
+}
+
+// CreateUser returns the handler creating a new user.
+// It decodes the user data from the request body, saves the user to the database,
+// and sends the newly created user data in the response with a 201 Created status.
+// The new user is emailed a token to verify their address, see VerifyEmail.
+func CreateUser(accounts *Accounts) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Create an empty User object to hold the incoming data.
+		user := models.User{}
+		// Decode the incoming JSON request body into the User object, rejecting unknown fields.
+		if errs := validation.DecodeJSON(r.Body, &user); len(errs) > 0 {
+			// If decoding fails, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+		} else {
+			// The timestamps are set by GORM, not by clients, and the address is not verified yet.
+			user.CreatedAt, user.UpdatedAt, user.DeletedAt, user.EmailVerifiedAt = time.Time{}, time.Time{}, gorm.DeletedAt{}, nil
+			// Only admins can choose the role of new users, everyone else signs up as a regular user.
+			if identity, _ := auth.IdentityFromContext(r.Context()); !identity.IsAdmin() || user.Role == "" {
+				user.Role = models.RoleUser
+			}
+			// Make sure the user can be saved, otherwise list the invalid fields.
+			if !validate(rw, r, &user) {
+				return
+			}
+			// Save the new user to the database.
+			if err := user.Save(r.Context()); err != nil {
+				// If the user cannot be saved, send an error response.
+				sendError(rw, r, err)
+				return
+			}
+			// The user is created even when the email cannot be sent, they can ask for another one.
+			if err := accounts.SendVerification(r.Context(), user); err != nil {
+				logging.FromContext(r.Context()).Error("verification email failed", "user_id", user.Id, "err", err)
+			}
+			// Send the newly created user in the response with a 201 Created status.
+			sendUser(rw, user, http.StatusCreated)
+		}
+	}
+}
+
+// DeleteUser handles the request to delete a user by their ID.
+// It attempts to fetch the user from the database, soft deletes it, and sends the deleted user's data in the response.
+// If the user is not found, it sends a "Not Found" response. The user can be restored until it is purged.
+func DeleteUser(rw http.ResponseWriter, r *http.Request) {
+	// Attempt to retrieve the user by ID from the request, at the version the request expects.
+	if user, ok := getUserToChange(rw, r); !ok {
+		return
+	} else if err := user.Delete(r.Context()); err != nil {
+		// If the user cannot be deleted, send an error response.
+		sendError(rw, r, err)
+	} else {
+		// Send the deleted user data in the response.
+		sendData(rw, user, http.StatusOK)
+	}
+}
+
+// RestoreUser handles the request to bring back a soft deleted user by their ID.
+// It fails with a 409 when another user has the username or email, which only
+// happens to users deleted before the unique indexes were added.
+func RestoreUser(rw http.ResponseWriter, r *http.Request) {
+	// Attempt to retrieve the deleted user by ID from the request.
+	user, err := getDeletedUserByID(r)
+	if err != nil {
+		// If the deleted user is not found, send an error response.
+		sendError(rw, r, err)
+	} else if err := user.ValidateUnique(r.Context()); err != nil {
+		// If the username or email is taken, send the taken fields.
+		sendError(rw, r, err)
+	} else if err := user.Restore(r.Context()); err != nil {
+		// If the user cannot be restored, send an error response.
+		sendError(rw, r, err)
+	} else {
+		// Send the restored user data in the response.
+		sendUser(rw, user, http.StatusOK)
+	}
+}
+
+// PurgeUser handles the request to remove a user for good by their ID, whether
+// it was soft deleted or not, and sends the removed user's data in the response.
+func PurgeUser(rw http.ResponseWriter, r *http.Request) {
+	// Look for the user among the active users first, then among the deleted ones.
+	user, err := getUserByID(r)
+	if errors.Is(err, models.ErrNotFound) {
+		user, err = getDeletedUserByID(r)
+	}
+
+	if err != nil {
+		// If user not found, send an error response.
+		sendError(rw, r, err)
+	} else if err := user.Purge(r.Context()); err != nil {
+		// If the user cannot be removed, send an error response.
+		sendError(rw, r, err)
+	} else {
+		// Send the removed user data in the response.
+		sendData(rw, user, http.StatusOK)
+	}
+}
+
+// UpdateUser returns the handler replacing an existing user's data.
+// It retrieves the user by their ID, decodes the new user data from the request body,
+// updates the user in the database, and sends the updated user data in the response.
+// Fields missing from the body are emptied, PatchUser only changes the fields it is sent.
+func UpdateUser(accounts *Accounts) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Try to retrieve the current user by ID from the request, at the version the request expects.
+		user_ant, ok := getUserToChange(rw, r)
+		if !ok {
+			return
+		}
+
+		user := models.User{}
+		// Decode the new user data from the request body, rejecting unknown fields.
+		if errs := validation.DecodeJSON(r.Body, &user); len(errs) > 0 {
+			// If decoding fails, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+
+		saveChanges(rw, r, accounts, user_ant, &user)
+	}
+}
+
+// PatchUser returns the handler changing some fields of an existing user.
+// The body is either a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902),
+// as told by its Content-Type, applied to the user as GetUser sends it.
+// The patched user is then validated and saved like in UpdateUser.
+func PatchUser(accounts *Accounts) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		// Tell clients which kinds of patch are accepted.
+		rw.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+
+		// Try to retrieve the current user by ID from the request, at the version the request expects.
+		user_ant, ok := getUserToChange(rw, r)
+		if !ok {
+			return
+		}
+
+		// Read the patch from the request body.
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(rw, r, apperr.BadRequest("The request body cannot be read"))
+			return
+		}
+
+		// Apply the patch to the user as clients see it, MarshalJSON leaves the password out.
+		original, err := json.Marshal(user_ant)
+		if err != nil {
+			sendError(rw, r, apperr.Internal(err))
+			return
+		}
+		patched, err := patch.Apply(r.Header.Get("Content-Type"), original, body)
+		if err != nil {
+			// If the patch cannot be applied, send the error response matching its kind.
+			sendError(rw, r, patchError(err))
+			return
+		}
+
+		user := models.User{}
+		// Decode the patched user, rejecting the fields a User does not have.
+		if errs := validation.DecodeJSON(bytes.NewReader(patched), &user); len(errs) > 0 {
+			// If decoding fails, send a 422 Unprocessable Entity response listing the problems.
+			sendError(rw, r, apperr.Validation(errs))
+			return
+		}
+
+		saveChanges(rw, r, accounts, user_ant, &user)
+	}
+}
+
+// saveChanges saves the new data of the current user sent by UpdateUser or PatchUser.
+// It keeps the fields clients cannot change, validates the user and sends the
+// saved user in the response, or the error that prevented saving it. A new
+// email address is sent a verification token through accounts.
+func saveChanges(rw http.ResponseWriter, r *http.Request, accounts *Accounts, user_ant models.User, user *models.User) {
+	// Assign the original user ID and version to the updated user to avoid overwriting them.
+	user.Id, user.Version = user_ant.Id, user_ant.Version
+	// The creation time never changes; GORM sets the update time.
+	user.CreatedAt, user.UpdatedAt, user.DeletedAt = user_ant.CreatedAt, user_ant.UpdatedAt, gorm.DeletedAt{}
+	// The address stays verified until it changes, then it needs a new verification.
+	user.EmailVerifiedAt = nil
+	if user.Email == user_ant.Email {
+		user.EmailVerifiedAt = user_ant.EmailVerifiedAt
+	}
+	// Keep the current password when the request does not send a new one,
+	// since clients never receive it and cannot send it back.
+	if user.Password == "" {
+		user.KeepPassword(user_ant)
+	}
+	// Only admins can change roles, users cannot promote themselves.
+	if identity, _ := auth.IdentityFromContext(r.Context()); !identity.IsAdmin() || user.Role == "" {
+		user.Role = user_ant.Role
+	}
+	// Make sure the user can be saved, otherwise list the invalid fields.
+	if !validate(rw, r, user) {
+		return
+	}
+	// Save the updated user to the database, unless another request changed it since it was read.
+	if err := user.Save(r.Context()); err != nil {
+		// If the user cannot be saved, send an error response.
+		sendError(rw, r, err)
+		return
+	}
+	// Ask the user to verify their new address.
+	if user.Email != user_ant.Email {
+		if err := accounts.SendVerification(r.Context(), *user); err != nil {
+			logging.FromContext(r.Context()).Error("verification email failed", "user_id", user.Id, "err", err)
+		}
+	}
+	// Send the updated user data in the response.
+	sendUser(rw, *user, http.StatusOK)
+}
+
+// patchError returns the error sent to the client when a patch cannot be applied:
+// unsupported content types are a 415, malformed patches a 400, failed "test"
+// operations a 409 and operations on fields that do not exist a 422.
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, patch.ErrUnsupportedType):
+		return apperr.UnsupportedMediaType("The body must be a JSON merge patch (" + patch.MergePatchType + ") or a JSON patch (" + patch.JSONPatchType + ")")
+	case errors.Is(err, patch.ErrTestFailed):
+		return apperr.Conflict(err.Error())
+	case errors.Is(err, patch.ErrCannotApply):
+		return apperr.New(apperr.ErrValidation, err.Error())
+	default:
+		return apperr.BadRequest(err.Error())
+	}
+}
+
+// validate checks the user before it is saved. When it is not valid, it sends a
+// 422 Unprocessable Entity response listing every invalid field, or a 409
+// Conflict naming the username or email already taken, and returns false.
+func validate(rw http.ResponseWriter, r *http.Request, user *models.User) bool {
+	if err := user.Validate(r.Context()); err != nil {
+		// Send the invalid or taken fields, or a 500 error response if the uniqueness checks failed.
+		sendError(rw, r, err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+// Package health answers the probes of an orchestrator such as Kubernetes.
+// The liveness probe only tells that the process still serves requests, the
+// readiness probe that its dependencies, e.g. MySQL, answer and that it is
+// not shutting down, so it should be sent new requests.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"logging"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Paths where the probes are served
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+// Check reports whether a dependency works, it returns an error when the
+// dependency does not answer before the deadline of ctx
+type Check func(ctx context.Context) error
+
+// Checker runs the checks of the readiness probe
+type Checker struct {
+	timeout  time.Duration    // Time given to the checks of a probe
+	names    []string         // Names of the dependencies, in the order they were added
+	checks   map[string]Check // Check of each dependency
+	draining atomic.Bool      // Set once the server starts shutting down
+}
+
+// NewChecker returns a Checker giving its checks at most timeout to answer
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Add registers the check of a dependency, it must be called before serving
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// Drain makes the readiness probe fail from now on, whatever the checks say,
+// so no new requests are sent while the server shuts down
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Status is the state of a dependency in the response of the readiness probe
+type Status struct {
+	Status    string  `json:"status"`          // "up" or "down"
+	Error     string  `json:"error,omitempty"` // Why the dependency is down, "timeout" or "unreachable"
+	LatencyMs float64 `json:"latency_ms"`      // Time the check took
+}
+
+// Report is the response of the readiness probe
+type Report struct {
+	Status   string            `json:"status"`   // "ready" or "unready"
+	Draining bool              `json:"draining"` // Whether the server is shutting down
+	Checks   map[string]Status `json:"checks"`   // State of each dependency
+}
+
+// Live answers the liveness probe, the process is alive as long as it responds
+func (c *Checker) Live(rw http.ResponseWriter, r *http.Request) {
+	send(rw, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready answers the readiness probe with a Report: 200 when every dependency
+// is up and the server is not draining, 503 otherwise
+func (c *Checker) Ready(rw http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	send(rw, status, report)
+}
+
+// Check runs every check at the same time and reports their results
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Status, len(c.names))
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.checks[name](ctx)
+			results[i] = Status{Status: "up", LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				// The probe is public, the error of the driver may tell the address or
+				// the user of the database, so only the logs have it.
+				logging.FromContext(ctx).Warn("Readiness check failed", "check", name, "err", err)
+				results[i].Status, results[i].Error = "down", "unreachable"
+				if errors.Is(err, context.DeadlineExceeded) {
+					results[i].Error = "timeout"
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ready", Draining: c.draining.Load(), Checks: map[string]Status{}}
+	if report.Draining {
+		report.Status = "unready"
+	}
+	for i, name := range c.names {
+		report.Checks[name] = results[i]
+		if results[i].Status != "up" {
+			report.Status = "unready"
+		}
+	}
+
+	return report
+}
+
+// send writes the body as JSON, never cached so every probe reaches the server
+func send(rw http.ResponseWriter, status int, body interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(body)
+}
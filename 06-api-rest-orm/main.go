@@ -1,39 +1,246 @@
-package main
-
-import (
-	"fmt"
-	"gorm/handlers"
-	"log"
-	"net/http"
-
-	"github.com/gorilla/mux"
-)
-
-func main() {
-	//models.MigrateUser()
-
-	// Initialize a new router using Gorilla Mux
-	mux := mux.NewRouter()
-
-	// Define the routes for the API and bind them to their corresponding handler functions
-	// GET /api/user/ - Retrieves the list of users
-	mux.HandleFunc("/api/user/", handlers.GetUsers).Methods("GET")
-
-	// GET /api/user/{id} - Retrieves a single user by their ID
-	mux.HandleFunc("/api/user/{id:[0-9]+}", handlers.GetUser).Methods("GET")
-
-	// POST /api/user/ - Creates a new user with the data in the request body
-	mux.HandleFunc("/api/user/", handlers.CreateUser).Methods("POST")
-
-	// PUT /api/user/{id} - Updates an existing user's data by their ID
-	mux.HandleFunc("/api/user/{id:[0-9]+}", handlers.UpdateUser).Methods("PUT")
-
-	// DELETE /api/user/{id} - Deletes a user by their ID
-	mux.HandleFunc("/api/user/{id:[0-9]+}", handlers.DeleteUser).Methods("DELETE")
-
-	// Print a message to the console indicating the server is running
-	fmt.Println("Run server: http://localhost:3000")
-
-	// Start the server on port 3000 and log any errors that occur
-	log.Fatal(http.ListenAndServe(":3000", mux))
-}
+package main
+
+import (
+	"auth"
+	"config"
+	"context"
+	"flag"
+	"gorm/db"
+	"gorm/handlers"
+	"gorm/health"
+	"gorm/metrics"
+	"gorm/migrations"
+	"gorm/models"
+	"gorm/openapi"
+	"log"
+	"log/slog"
+	"logging"
+	"mail"
+	"migrate"
+	"net/http"
+	"os"
+	"ratelimit"
+	"security"
+	"server"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// issuer is the "iss" claim of the tokens, unless JWT_ISSUER is set
+const issuer = "gorm"
+
+// Deadlines of the routes that do not follow the query timeout of the
+// settings (database.query_timeout), see newRouter
+const (
+	bulkTimeout  = 10 * time.Minute // Importing or exporting many users
+	readyTimeout = 2 * time.Second  // Checking the database for the readiness probe
+)
+
+// Largest request bodies accepted, the client gets a 413 over them
+const (
+	maxBodyBytes   = 1 << 20  // JSON documents, 1 MiB
+	maxImportBytes = 32 << 20 // CSV or NDJSON imports, 32 MiB
+)
+
+// Rate limits of the routes, for each client
+var (
+	loginLimit   = ratelimit.Limit{Requests: 10, Per: time.Minute}  // Logging in, slows down password guessing
+	refreshLimit = ratelimit.Limit{Requests: 30, Per: time.Minute}  // Refreshing tokens
+	signUpLimit  = ratelimit.Limit{Requests: 20, Per: time.Hour}    // Creating users
+	bulkLimit    = ratelimit.Limit{Requests: 10, Per: time.Hour}    // Importing or exporting many users
+	userLimit    = ratelimit.Limit{Requests: 300, Per: time.Minute} // Any other route of /api/user/
+	verifyLimit  = ratelimit.Limit{Requests: 10, Per: time.Hour}    // Verifying an email address or asking for a new token
+	forgotLimit  = ratelimit.Limit{Requests: 5, Per: time.Hour}     // Asking for a password reset email, each one sends an email
+	resetLimit   = ratelimit.Limit{Requests: 10, Per: time.Hour}    // Resetting a password, slows down token guessing
+)
+
+func main() {
+	// Write the logs as JSON lines, those of the log package included
+	slog.SetDefault(logging.NewLogger(os.Stdout))
+
+	// Load the database and server settings from the defaults, the -config
+	// file, the environment and the command line, in that order
+	cfg := config.Default()
+	cfg.Database.Params = "charset=utf8mb4&parseTime=True&loc=Local"
+	if err := config.Load(&cfg, flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Settings", "config", config.Redacted(cfg))
+	if cfg.Database.QueryTimeout <= 0 {
+		log.Fatal("config: database.query_timeout (DB_QUERY_TIMEOUT) must be positive, it sets the deadlines of the routes")
+	}
+
+	// "migrate up|down|status|create NAME" manages the schema of the database and exits
+	if flag.Arg(0) == "migrate" {
+		if err := migrate.Command(context.Background(), cfg.Database, migrations.Files, migrations.Dir, migrations.Table, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Connect to the database before anything uses the models
+	if err := cfg.Database.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.Open(cfg.Database); err != nil {
+		log.Fatal(err)
+	}
+
+	// Publish the state of the connection pool used by GORM
+	metrics.RegisterDBStats("mysql", db.Stats)
+
+	// Report the database as a dependency of the readiness probe
+	checker := health.NewChecker(readyTimeout)
+	checker.Add("mysql", db.Ping)
+
+	// Only let the configured origins call the API from a browser
+	corsConfig := security.CORSConfigFrom(cfg.Server.CORS)
+	if err := corsConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the token settings (JWT_SECRET, JWT_ALGORITHM, ...) from the environment
+	authConfig, err := auth.ConfigFromEnv(issuer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokens, err := auth.NewTokenManager(authConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Send the emails with the driver of the settings (MAIL_DRIVER=smtp, file or memory)
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the first admin from ADMIN_USERNAME, ADMIN_PASSWORD and ADMIN_EMAIL, if set
+	if username, password := os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD"); username != "" && password != "" {
+		if err := models.EnsureAdmin(context.Background(), username, password, os.Getenv("ADMIN_EMAIL")); err != nil {
+			log.Fatalf("Check ADMIN_USERNAME, ADMIN_PASSWORD and ADMIN_EMAIL: %v", err)
+		}
+	}
+
+	// Route the requests to the handlers
+	limiter := ratelimit.New(ratelimit.NewMemoryStore())
+	mux := newRouter(tokens, handlers.NewAccounts(tokens, mailer), checker, limiter, cfg.Database.QueryTimeout)
+
+	// Log a message indicating the server is running
+	serverConfig := server.ConfigFrom(cfg.Server)
+	slog.Info("Run server", "url", serverConfig.URL())
+
+	// Every request gets the security and CORS headers, an ID, a line in the
+	// access log and is counted in the metrics
+	var handler http.Handler = mux
+	handler = security.CORS(corsConfig, mux)(handler)
+	handler = security.Headers(handler)
+	handler = logging.Middleware(slog.Default(), mux)(handler)
+	handler = metrics.Middleware(mux)(handler)
+
+	// Serve until SIGINT or SIGTERM, then fail the readiness probe, drain the
+	// requests and close the database.
+	if err := server.Run(serverConfig, handler, checker.Drain, closeDatabase); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newRouter registers every route of the API on a new router. The routes of
+// /api/user/ are described by openapi.Spec, keep both in sync.
+func newRouter(tokens *auth.TokenManager, accounts *handlers.Accounts, checker *health.Checker, limiter *ratelimit.Limiter, queryTimeout time.Duration) *mux.Router {
+	// Give every route a deadline, the client gets a 504 when it passes.
+	// Listing users and saving users, which hashes passwords, get the query
+	// timeout, fetching a single user half of it.
+	read := handlers.Timeout(queryTimeout / 2)
+	list := handlers.Timeout(queryTimeout)
+	write := handlers.Timeout(queryTimeout)
+	bulk := handlers.LongTimeout(bulkTimeout)
+
+	// Limit how often each client calls a route, the client gets a 429 over the limit.
+	// Anonymous clients are told apart by IP address, authenticated ones by user.
+	byIP := func(route string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+		return limiter.Limit(route, limit, ratelimit.ByIP)
+	}
+	byUser := func(route string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+		return limiter.Limit(route, limit, ratelimit.ByUserOrIP)
+	}
+
+	// Limit the size of the request bodies, imports are streamed instead of read at once
+	body := security.LimitBody(maxBodyBytes)
+	stream := security.LimitStream(maxImportBytes)
+
+	// Initialize a new router using Gorilla Mux
+	mux := mux.NewRouter()
+
+	// POST /api/login - Checks the credentials and returns access and refresh tokens
+	mux.Handle("/api/login", write(body(byIP("login", loginLimit)(handlers.Login(tokens))))).Methods("POST")
+
+	// POST /api/token/refresh - Exchanges a refresh token for a new pair of tokens
+	mux.Handle("/api/token/refresh", read(body(byIP("refresh", refreshLimit)(handlers.Refresh(tokens))))).Methods("POST")
+
+	// POST /api/password/forgot - Emails a password reset token to the user with the address in the body
+	mux.Handle("/api/password/forgot", write(body(byIP("forgot", forgotLimit)(handlers.ForgotPassword(accounts))))).Methods("POST")
+
+	// POST /api/password/reset - Sets a new password with the token received by email
+	mux.Handle("/api/password/reset", write(body(byIP("reset", resetLimit)(handlers.ResetPassword(accounts))))).Methods("POST")
+
+	// Define the routes for the API and bind them to their corresponding handler functions
+	// GET /api/user/ - Retrieves the list of users (admins only)
+	mux.Handle("/api/user/", list(tokens.Require(byUser("list", userLimit)(auth.AdminOnly(http.HandlerFunc(handlers.GetUsers)))))).Methods("GET")
+
+	// POST /api/user/import - Creates many users from a CSV or NDJSON body (admins only)
+	mux.Handle("/api/user/import", bulk(stream(tokens.Require(byUser("import", bulkLimit)(auth.AdminOnly(http.HandlerFunc(handlers.ImportUsers))))))).Methods("POST")
+
+	// GET /api/user/export?format=csv|ndjson - Streams every user (admins only)
+	mux.Handle("/api/user/export", bulk(tokens.Require(byUser("export", bulkLimit)(auth.AdminOnly(http.HandlerFunc(handlers.ExportUsers)))))).Methods("GET")
+
+	// GET /api/user/{id} - Retrieves a single user by their ID (the user themselves or an admin)
+	mux.Handle("/api/user/{id:[0-9]+}", read(tokens.Require(byUser("get", userLimit)(auth.SelfOrAdmin(http.HandlerFunc(handlers.GetUser)))))).Methods("GET")
+
+	// POST /api/user/ - Creates a new user with the data in the request body (open for sign up)
+	mux.Handle("/api/user/", write(body(tokens.Optional(byUser("signup", signUpLimit)(handlers.CreateUser(accounts)))))).Methods("POST")
+
+	// PUT /api/user/{id} - Updates an existing user's data by their ID (the user themselves or an admin)
+	mux.Handle("/api/user/{id:[0-9]+}", write(body(tokens.Require(byUser("update", userLimit)(auth.SelfOrAdmin(handlers.UpdateUser(accounts))))))).Methods("PUT")
+
+	// PATCH /api/user/{id} - Changes some fields of a user with a merge patch or a JSON patch (the user themselves or an admin)
+	mux.Handle("/api/user/{id:[0-9]+}", write(body(tokens.Require(byUser("patch", userLimit)(auth.SelfOrAdmin(handlers.PatchUser(accounts))))))).Methods("PATCH")
+
+	// DELETE /api/user/{id} - Soft deletes a user by their ID, it can still be restored (admins only)
+	mux.Handle("/api/user/{id:[0-9]+}", write(tokens.Require(byUser("delete", userLimit)(auth.AdminOnly(http.HandlerFunc(handlers.DeleteUser)))))).Methods("DELETE")
+
+	// POST /api/user/{id}/restore - Brings back a deleted user by their ID (admins only)
+	mux.Handle("/api/user/{id:[0-9]+}/restore", write(tokens.Require(byUser("restore", userLimit)(auth.AdminOnly(http.HandlerFunc(handlers.RestoreUser)))))).Methods("POST")
+
+	// POST /api/user/{id}/verify-email - Verifies the email address with the token received by email,
+	// or sends a new token when the body has none (the user themselves or an admin)
+	mux.Handle("/api/user/{id:[0-9]+}/verify-email", write(body(tokens.Optional(byUser("verify", verifyLimit)(handlers.VerifyEmail(accounts)))))).Methods("POST")
+
+	// DELETE /api/user/{id}/purge - Removes a user for good by their ID, deleted or not (admins only)
+	mux.Handle("/api/user/{id:[0-9]+}/purge", write(tokens.Require(byUser("purge", userLimit)(auth.AdminOnly(http.HandlerFunc(handlers.PurgeUser)))))).Methods("DELETE")
+
+	// GET /openapi.json - Describes the user API in an OpenAPI 3 document
+	mux.HandleFunc(openapi.SpecPath, openapi.ServeSpec).Methods("GET")
+
+	// GET /docs - Browses the OpenAPI document with Swagger UI
+	mux.PathPrefix(openapi.DocsPath).Handler(openapi.Docs()).Methods("GET")
+
+	// GET /metrics - Sends the request, connection pool and query metrics to Prometheus
+	mux.Handle(metrics.Path, metrics.Handler()).Methods("GET")
+
+	// GET /healthz - Answers the liveness probe while the process runs
+	mux.HandleFunc(health.LivePath, checker.Live).Methods("GET")
+
+	// GET /readyz - Answers the readiness probe, 503 when the database is down or the server shuts down
+	mux.HandleFunc(health.ReadyPath, checker.Ready).Methods("GET")
+
+	return mux
+}
+
+// closeDatabase closes the connection pool once the server has stopped
+func closeDatabase() {
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing the database", "err", err)
+	}
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"auth"
+	"context"
+	"encoding/json"
+	"gorm/db"
+	"gorm/handlers"
+	"gorm/health"
+	"gorm/models"
+	"gorm/openapi"
+	"mail"
+	"net/http"
+	"net/http/httptest"
+	"patch"
+	"ratelimit"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/glebarez/sqlite"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// problem is the Accept header of clients asking for RFC 7807 errors
+const problem = "application/problem+json"
+
+// contractCase is a request sent to the API and the status it must get back
+type contractCase struct {
+	method, path string
+	as           string            // Who sends the request: "admin", "user", "anonymous" or a raw Authorization header
+	header       map[string]string // Other headers of the request
+	body         string
+	status       int
+}
+
+// loadSpec parses and validates the OpenAPI document and returns it with a
+// router finding the operation matching a request
+func loadSpec(tb testing.TB) (*openapi3.T, routers.Router) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(openapi.Spec)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := spec.Validate(loader.Context); err != nil {
+		tb.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return spec, router
+}
+
+// newTestDB replaces the database of the models with an empty SQLite database
+// in memory holding their tables, until the end of the test
+func newTestDB(tb testing.TB) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// Every connection to ":memory:" would get its own empty database
+	sqlDB.SetMaxOpenConns(1)
+	if err := conn.AutoMigrate(&models.User{}, &models.Token{}); err != nil {
+		tb.Fatal(err)
+	}
+
+	previous := db.Database
+	db.Database = conn
+	tb.Cleanup(func() {
+		db.Database = previous
+		sqlDB.Close()
+	})
+}
+
+// newTestAPI returns the router of the API on top of a SQLite database holding
+// an admin (ID 1) and a regular user (ID 2), with an access token for each and
+// the mailer keeping the emails sent
+func newTestAPI(tb testing.TB) (*mux.Router, map[string]string, *mail.Memory) {
+	newTestDB(tb)
+	admin := &models.User{Username: "boss", Password: "supersecret1", Email: "boss@example.com", Role: models.RoleAdmin}
+	alex := &models.User{Username: "alex", Password: "password1", Email: "alex@example.com", Role: models.RoleUser}
+	for _, user := range []*models.User{admin, alex} {
+		if err := user.Save(context.Background()); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	// Sign an access token for each of them, anonymous requests have none
+	cfg := auth.DefaultConfig(issuer)
+	cfg.Secret = "0123456789abcdef0123456789abcdef"
+	tokens, err := auth.NewTokenManager(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	headers := map[string]string{"anonymous": ""}
+	for as, user := range map[string]*models.User{"admin": admin, "user": alex} {
+		pair, err := tokens.Issue(user.Id, user.Username, user.Role)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		headers[as] = "Bearer " + pair.AccessToken
+	}
+
+	mailer := mail.NewMemory()
+	return newRouter(tokens, handlers.NewAccounts(tokens, mailer), health.NewChecker(time.Second), ratelimit.New(ratelimit.NewMemoryStore()), 10*time.Second), headers, mailer
+}
+
+// TestContract sends requests covering every operation of the OpenAPI document
+// and checks each response against it: the status must be documented and the
+// headers and body must match its schemas. Requests that succeed must match
+// the document too. The cases run in order, later ones depend on earlier ones.
+func TestContract(t *testing.T) {
+	// Merge patches are JSON documents too
+	openapi3filter.RegisterBodyDecoder(patch.MergePatchType, openapi3filter.JSONBodyDecoder)
+
+	spec, specRouter := loadSpec(t)
+	api, authorization, _ := newTestAPI(t)
+
+	// Define the sequence of requests, the users are alex (ID 2) and the new sam (ID 3)
+	table := []contractCase{
+		{"GET", "/api/user/", "admin", nil, "", 200},
+		{"GET", "/api/user/", "anonymous", nil, "", 401},
+		{"GET", "/api/user/", "user", nil, "", 403},
+		{"GET", "/api/user/", "user", map[string]string{"Accept": problem}, "", 403},
+		{"POST", "/api/user/", "anonymous", nil, `{"username":"sam","password":"password1","email":"sam@example.com"}`, 201},
+		{"POST", "/api/user/", "admin", nil, `{"username":"kim","password":"password1","email":"kim@example.com","role":"admin"}`, 201},
+		{"POST", "/api/user/", "anonymous", nil, `{"username":"sam","password":"password1","email":"sam@example.org"}`, 409},
+		{"POST", "/api/user/", "anonymous", nil, `{"username":"","password":"short","email":"sam"}`, 422},
+		{"POST", "/api/user/", "anonymous", map[string]string{"Accept": problem}, `{"nickname":"sam"}`, 422},
+		{"POST", "/api/user/", "anonymous", map[string]string{"Accept": problem}, `{"username":"sam","password":1}`, 422},
+		{"POST", "/api/user/", "anonymous", nil, `{"username":"` + strings.Repeat("a", maxBodyBytes) + `"}`, 413},
+		{"POST", "/api/user/", "Bearer nope", nil, `{"username":"sam","password":"password1","email":"sam@example.com"}`, 401},
+		{"GET", "/api/user/2", "user", nil, "", 200},
+		{"GET", "/api/user/3", "user", nil, "", 403},
+		{"GET", "/api/user/2", "anonymous", nil, "", 401},
+		{"GET", "/api/user/99", "admin", nil, "", 404},
+		{"GET", "/api/user/99", "admin", map[string]string{"Accept": problem}, "", 404},
+		{"PUT", "/api/user/2", "user", map[string]string{"If-Match": `"1"`}, `{"username":"alex","email":"alex@example.org"}`, 200},
+		{"PUT", "/api/user/2", "user", map[string]string{"If-Match": `"1"`}, `{"username":"alex","email":"alex@example.org"}`, 412},
+		{"PUT", "/api/user/2", "user", nil, `{"username":"alex"}`, 422},
+		{"PUT", "/api/user/2", "user", nil, `{"username":"boss","email":"alex@example.org"}`, 409},
+		{"PUT", "/api/user/3", "user", nil, `{"username":"sam","email":"sam@example.com"}`, 403},
+		{"PUT", "/api/user/2", "Bearer nope", nil, `{"username":"alex","email":"alex@example.com"}`, 401},
+		{"PUT", "/api/user/99", "admin", nil, `{"username":"nobody","email":"nobody@example.com"}`, 404},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "application/merge-patch+json"}, `{"email":"alex@example.net"}`, 200},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "application/json-patch+json", "If-Match": `"3"`}, `[{"op":"replace","path":"/username","value":"alexa"}]`, 200},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "application/json-patch+json"}, `[{"op":"test","path":"/username","value":"alex"}]`, 409},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "application/json-patch+json"}, `[{"op":"remove","path":"/nickname"}]`, 422},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "application/json-patch+json"}, `{"op":"remove"}`, 400},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "text/plain"}, `email=alex@example.net`, 415},
+		{"PATCH", "/api/user/2", "user", map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"1"`}, `{}`, 412},
+		{"PATCH", "/api/user/3", "user", map[string]string{"Content-Type": "application/merge-patch+json"}, `{"email":"sam@example.net"}`, 403},
+		{"PATCH", "/api/user/2", "anonymous", map[string]string{"Accept": problem}, `{}`, 401},
+		{"DELETE", "/api/user/3", "user", nil, "", 403},
+		{"DELETE", "/api/user/3", "anonymous", nil, "", 401},
+		{"DELETE", "/api/user/3", "admin", map[string]string{"If-Match": `"2"`}, "", 412},
+		{"DELETE", "/api/user/3", "admin", map[string]string{"If-Match": `"1"`}, "", 200},
+		{"DELETE", "/api/user/3", "admin", nil, "", 404},
+		{"GET", "/api/user/?deleted=true", "admin", nil, "", 200},
+	}
+
+	// Remember which operations succeeded at least once
+	succeeded := map[string]bool{}
+
+	// Loop through each test case
+	for _, item := range table {
+		name := item.method + " " + item.path + " as " + item.as
+
+		// Build the request
+		r := httptest.NewRequest(item.method, item.path, strings.NewReader(item.body))
+		if header, ok := authorization[item.as]; ok {
+			if header != "" {
+				r.Header.Set("Authorization", header)
+			}
+		} else {
+			r.Header.Set("Authorization", item.as)
+		}
+		if item.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		for key, value := range item.header {
+			r.Header.Set(key, value)
+		}
+
+		// Find the operation documented for the request
+		route, pathParams, err := specRouter.FindRoute(r)
+		if err != nil {
+			t.Errorf("%s: not in the OpenAPI document: %v", name, err)
+			continue
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+		}
+		// The validation reads the body and puts it back for the API
+		requestErr := openapi3filter.ValidateRequest(context.Background(), input)
+
+		// Send the request to the API
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, r)
+		if rw.Code != item.status {
+			t.Errorf("%s: incorrect status, got %d, expected %d: %s", name, rw.Code, item.status, rw.Body)
+			continue
+		}
+
+		// Requests the API accepts must be valid for the document, and the other way round
+		if rw.Code < 400 && requestErr != nil {
+			t.Errorf("%s: accepted by the API but invalid for the OpenAPI document: %v", name, requestErr)
+		}
+		if rw.Code < 400 {
+			succeeded[route.Method+" "+route.Path] = true
+		}
+
+		// Check the status, the headers and the body of the response
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rw.Code,
+			Header:                 rw.Header(),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		responseInput.SetBodyBytes(rw.Body.Bytes())
+		if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+			t.Errorf("%s: response does not match the OpenAPI document: %v\n%s", name, err, rw.Body)
+		}
+	}
+
+	// Every operation of the document must have been seen working
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			if !succeeded[method+" "+path] {
+				t.Errorf("No successful request for %s %s", method, path)
+			}
+		}
+	}
+}
+
+// TestUserSchema checks that users, active or soft deleted, are sent as the
+// User schema of the OpenAPI document describes them.
+func TestUserSchema(t *testing.T) {
+	spec, _ := loadSpec(t)
+	schema := spec.Components.Schemas["User"].Value
+
+	now := time.Now()
+	active := models.User{Id: 1, Username: "alex", Password: "$2a$10$hash", Email: "alex@example.com", Role: models.RoleUser, CreatedAt: now, UpdatedAt: now, Version: 1}
+	deleted := active
+	deleted.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	verified := active
+	verified.EmailVerifiedAt = &now
+
+	// Loop through each user, decoded from JSON as clients see it
+	for _, user := range []models.User{active, deleted, verified} {
+		output, err := json.Marshal(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(output, &value); err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.VisitJSON(value); err != nil {
+			t.Errorf("User %s does not match the OpenAPI document: %v", output, err)
+		}
+	}
+}
+
+// routeVariable matches the pattern of a route variable, e.g. ":[0-9]+" in {id:[0-9]+}
+var routeVariable = regexp.MustCompile(`:[^}]+`)
+
+// TestRoutes checks that the document and the router describe the same
+// operations on /api/user/ and /api/user/{id}: nothing documented is missing
+// from the router and no route was added without documenting it.
+func TestRoutes(t *testing.T) {
+	spec, _ := loadSpec(t)
+	api, _, _ := newTestAPI(t)
+
+	// Collect the documented operations
+	documented := map[string]bool{}
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	// Collect the routes of the documented paths
+	routed := map[string]bool{}
+	api.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		path := routeVariable.ReplaceAllString(template, "")
+		if spec.Paths.Find(path) == nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			routed[method+" "+path] = true
+		}
+		return nil
+	})
+
+	for operation := range documented {
+		if !routed[operation] {
+			t.Errorf("%s is documented but not routed", operation)
+		}
+	}
+	for operation := range routed {
+		if !documented[operation] {
+			t.Errorf("%s is routed but not documented", operation)
+		}
+	}
+}
+
+// TestDocs checks that the document and the Swagger UI are served.
+func TestDocs(t *testing.T) {
+	api, _, _ := newTestAPI(t)
+
+	// Define a table of test cases with a path and the expected content type
+	table := []struct {
+		path, contentType string
+	}{
+		{openapi.SpecPath, "application/json"},
+		{openapi.DocsPath, "text/html"},
+		{openapi.DocsPath + "/swagger-ui-bundle.js", "javascript"},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("GET", item.path, nil))
+		if rw.Code != http.StatusOK || !strings.Contains(rw.Header().Get("Content-Type"), item.contentType) {
+			t.Errorf("Incorrect response for %s, got %d %s, expected %d %s", item.path, rw.Code, rw.Header().Get("Content-Type"), http.StatusOK, item.contentType)
+		}
+	}
+}
+
+// TestAccountRoutes checks the answers of the email verification and password
+// reset routes to the requests refused before reaching the database.
+func TestAccountRoutes(t *testing.T) {
+	api, authorization, _ := newTestAPI(t)
+
+	// Sign a token of each kind for alex (ID 2), they are refused on other routes and users
+	cfg := auth.DefaultConfig(issuer)
+	cfg.Secret = "0123456789abcdef0123456789abcdef"
+	tokens, err := auth.NewTokenManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify, _ := tokens.IssueOneTime(2, auth.VerifyEmailToken, "0123456789abcdef", time.Hour)
+	reset, _ := tokens.IssueOneTime(2, auth.ResetPasswordToken, "0123456789abcdef", time.Hour)
+	expired, _ := tokens.IssueOneTime(2, auth.VerifyEmailToken, "0123456789abcdef", -time.Hour)
+
+	// Define a table of test cases with a request and the expected status
+	table := []struct {
+		path, as, body string
+		status         int
+	}{
+		{"/api/user/2/verify-email", "anonymous", `{}`, http.StatusUnauthorized},
+		{"/api/user/3/verify-email", "user", `{}`, http.StatusForbidden},
+		{"/api/user/2/verify-email", "anonymous", `{"token":"nope"}`, http.StatusUnprocessableEntity},
+		{"/api/user/2/verify-email", "anonymous", `{"code":"nope"}`, http.StatusUnprocessableEntity},
+		{"/api/user/2/verify-email", "anonymous", `{"token":"` + reset + `"}`, http.StatusUnprocessableEntity},
+		{"/api/user/2/verify-email", "anonymous", `{"token":"` + expired + `"}`, http.StatusUnprocessableEntity},
+		{"/api/user/3/verify-email", "anonymous", `{"token":"` + verify + `"}`, http.StatusUnprocessableEntity},
+		{"/api/password/forgot", "anonymous", `{"email":"alex"}`, http.StatusUnprocessableEntity},
+		{"/api/password/reset", "anonymous", `{"token":"` + reset + `","password":"short"}`, http.StatusUnprocessableEntity},
+		{"/api/password/reset", "anonymous", `{"token":"` + verify + `","password":"password2"}`, http.StatusUnprocessableEntity},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		r := httptest.NewRequest("POST", item.path, strings.NewReader(item.body))
+		r.Header.Set("Content-Type", "application/json")
+		if authorization[item.as] != "" {
+			r.Header.Set("Authorization", authorization[item.as])
+		}
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, r)
+		if rw.Code != item.status {
+			t.Errorf("POST %s as %s with %s: incorrect status, got %d, expected %d: %s", item.path, item.as, item.body, rw.Code, item.status, rw.Body)
+		}
+	}
+}
+
+// mailedToken finds the token in the body of an email
+var mailedToken = regexp.MustCompile(`"token": "([^"]+)"`)
+
+// TestAccountEmails walks through the email verification and password reset
+// of a new user, finding the tokens in the emails kept by the memory mailer.
+// The cases run in order, later ones depend on earlier ones.
+func TestAccountEmails(t *testing.T) {
+	api, authorization, mailer := newTestAPI(t)
+
+	// send sends the request and returns the response
+	send := func(method, path, as, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if authorization[as] != "" {
+			r.Header.Set("Authorization", authorization[as])
+		}
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, r)
+		return rw
+	}
+	// lastToken returns the token of the last email sent to the address with the given subject
+	lastToken := func(to, subject string) string {
+		msg, ok := mailer.Last(to)
+		if !ok || msg.Subject != subject {
+			t.Fatalf("Incorrect last email to %s, got %+v, expected %q", to, msg, subject)
+		}
+		match := mailedToken.FindStringSubmatch(msg.Body)
+		if match == nil {
+			t.Fatalf("No token in the email to %s:\n%s", to, msg.Body)
+		}
+		return match[1]
+	}
+
+	// Signing up sends the verification email to sam (ID 3)
+	if rw := send("POST", "/api/user/", "anonymous", `{"username":"sam","password":"password1","email":"sam@example.com"}`); rw.Code != http.StatusCreated || !strings.Contains(rw.Body.String(), `"email_verified_at":null`) {
+		t.Fatalf("Incorrect sign up, got %d %s", rw.Code, rw.Body)
+	}
+	verify := lastToken("sam@example.com", "Verify your email address")
+
+	// Asking for a new token while sam and alex are not verified
+	anotherVerify := func() string {
+		if rw := send("POST", "/api/user/2/verify-email", "user", `{}`); rw.Code != http.StatusAccepted {
+			t.Fatalf("Incorrect status asking for a new token, got %d, expected %d: %s", rw.Code, http.StatusAccepted, rw.Body)
+		}
+		return lastToken("alex@example.com", "Verify your email address")
+	}
+	revoked := anotherVerify()
+	alexVerify := anotherVerify()
+
+	// Define a table of test cases with a request and the expected status
+	table := []struct {
+		method, path, as, body string
+		status                 int
+	}{
+		{"POST", "/api/user/3/verify-email", "anonymous", `{"token":"nope"}`, http.StatusUnprocessableEntity},
+		{"POST", "/api/user/2/verify-email", "anonymous", `{"token":"` + verify + `"}`, http.StatusUnprocessableEntity},  // Token of another user
+		{"POST", "/api/user/2/verify-email", "anonymous", `{"token":"` + revoked + `"}`, http.StatusUnprocessableEntity}, // Replaced by a newer token
+		{"POST", "/api/user/3/verify-email", "anonymous", `{"token":"` + verify + `"}`, http.StatusOK},
+		{"POST", "/api/user/3/verify-email", "anonymous", `{"token":"` + verify + `"}`, http.StatusUnprocessableEntity}, // Already used
+		{"POST", "/api/user/2/verify-email", "anonymous", `{"token":"` + alexVerify + `"}`, http.StatusOK},
+		{"POST", "/api/user/3/verify-email", "anonymous", `{}`, http.StatusUnauthorized},
+		{"POST", "/api/user/3/verify-email", "user", `{}`, http.StatusForbidden},
+		{"POST", "/api/user/3/verify-email", "admin", `{}`, http.StatusConflict}, // Already verified
+		{"POST", "/api/user/99/verify-email", "admin", `{}`, http.StatusNotFound},
+		{"POST", "/api/password/forgot", "anonymous", `{"email":"sam"}`, http.StatusUnprocessableEntity},
+		{"POST", "/api/password/forgot", "anonymous", `{"email":"nobody@example.com"}`, http.StatusAccepted},
+		{"POST", "/api/password/reset", "anonymous", `{"token":"nope","password":"password2"}`, http.StatusUnprocessableEntity},
+		{"POST", "/api/password/reset", "anonymous", `{"token":"` + verify + `","password":"password2"}`, http.StatusUnprocessableEntity}, // Token of another kind
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		if rw := send(item.method, item.path, item.as, item.body); rw.Code != item.status {
+			t.Errorf("%s %s as %s: incorrect status, got %d, expected %d: %s", item.method, item.path, item.as, rw.Code, item.status, rw.Body)
+		}
+	}
+	if _, ok := mailer.Last("nobody@example.com"); ok {
+		t.Errorf("Incorrect email sent to an unknown address")
+	}
+
+	// Reset the password of sam, the token only works once
+	if rw := send("POST", "/api/password/forgot", "anonymous", `{"email":"sam@example.com"}`); rw.Code != http.StatusAccepted {
+		t.Fatalf("Incorrect status asking for a password reset, got %d, expected %d", rw.Code, http.StatusAccepted)
+	}
+	reset := lastToken("sam@example.com", "Reset your password")
+	if rw := send("POST", "/api/password/reset", "anonymous", `{"token":"`+reset+`","password":"short"}`); rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Incorrect status resetting to a short password, got %d, expected %d", rw.Code, http.StatusUnprocessableEntity)
+	}
+	if rw := send("POST", "/api/password/reset", "anonymous", `{"token":"`+reset+`","password":"password2"}`); rw.Code != http.StatusOK {
+		t.Errorf("Incorrect status resetting the password, got %d, expected %d: %s", rw.Code, http.StatusOK, rw.Body)
+	}
+	if rw := send("POST", "/api/password/reset", "anonymous", `{"token":"`+reset+`","password":"password3"}`); rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Incorrect status reusing the reset token, got %d, expected %d", rw.Code, http.StatusUnprocessableEntity)
+	}
+
+	// Only the new password works
+	for password, status := range map[string]int{"password1": http.StatusUnauthorized, "password2": http.StatusOK} {
+		if rw := send("POST", "/api/login", "anonymous", `{"username":"sam","password":"`+password+`"}`); rw.Code != status {
+			t.Errorf("Incorrect status logging in with %s, got %d, expected %d", password, rw.Code, status)
+		}
+	}
+}
+
+// TestEnsureAdmin checks that the admin of the environment is validated,
+// created once, and restored when it was deleted.
+func TestEnsureAdmin(t *testing.T) {
+	ctx := context.Background()
+	newTestDB(t)
+	alex := &models.User{Username: "alex", Password: "password1", Email: "alex@example.com", Role: models.RoleUser}
+	if err := alex.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := alex.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Define a table of test cases with the credentials and whether the admin is accepted
+	table := []struct {
+		username, password, email string
+		valid                     bool
+	}{
+		{"boss", "supersecret1", "", false},
+		{"boss", "supersecret1", "boss", false},
+		{"boss", "short", "boss@example.com", false},
+		{"boss", "supersecret1", "alex@example.com", false}, // Email of the deleted alex
+		{"boss", "supersecret1", "boss@example.com", true},
+		{"boss", "supersecret1", "boss@example.com", true}, // Already created
+		{"alex", "supersecret1", "alex@example.org", true}, // Deleted regular user, left alone
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		if err := models.EnsureAdmin(ctx, item.username, item.password, item.email); (err == nil) != item.valid {
+			t.Errorf("Incorrect error for %s <%s>, got %v, expected valid %v", item.username, item.email, err, item.valid)
+		}
+	}
+	if _, err := models.FindUserByUsername(ctx, "alex"); err == nil {
+		t.Errorf("Incorrect restore of the deleted regular user alex")
+	}
+
+	// Deleting the admin does not keep the server from starting, it comes back
+	boss, err := models.FindUserByUsername(ctx, "boss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := boss.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.EnsureAdmin(ctx, "boss", "supersecret1", "boss@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := models.FindUserByUsername(ctx, "boss"); err != nil || restored.Id != boss.Id || !restored.IsAdmin() {
+		t.Errorf("Incorrect admin, got %+v %v, expected the restored admin %d", restored, err, boss.Id)
+	}
+}
@@ -0,0 +1,123 @@
+// Package metrics exposes the Prometheus metrics of the server: the number
+// and latency of the requests per route and status, the state of the SQL
+// connection pool and the duration of the statements run by the models.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is where the metrics are served
+const Path = "/metrics"
+
+// unmatchedRoute labels the requests no route matched, so scans of random
+// paths add a single series instead of one per path
+const unmatchedRoute = "unmatched"
+
+// Registry holds every metric of the server, together with those of the Go
+// runtime and the process
+var Registry = prometheus.NewRegistry()
+
+// Metrics of the HTTP requests, labeled by method, route template and status
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests served.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve the HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		queryDuration,
+	)
+}
+
+// Handler returns the handler sending the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware returns a middleware counting the requests and timing them,
+// labeled by method, status and the template of the route of the router
+// they match, e.g. /api/user/{id:[0-9]+}, so the IDs in the paths do not
+// create a series each.
+func Middleware(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			route := unmatchedRoute
+			var match mux.RouteMatch
+			if router.Match(r, &match) && match.Route != nil {
+				route, _ = match.Route.GetPathTemplate()
+			}
+
+			// Record the request once it is done, a handler that panics counts as a 500
+			recorder := &statusRecorder{ResponseWriter: rw}
+			defer func() {
+				aborted := recover()
+				status := recorder.status
+				switch {
+				case aborted != nil:
+					status = http.StatusInternalServerError
+				case status == 0:
+					// net/http sends a 200 once the handler returns without writing
+					status = http.StatusOK
+				}
+				labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+				requestsTotal.With(labels).Inc()
+				requestDuration.With(labels).Observe(time.Since(start).Seconds())
+				if aborted != nil {
+					panic(aborted)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// statusRecorder remembers the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int // Status sent, 0 until then
+}
+
+// WriteHeader records the status before sending it
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 status before writing the body
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+// Unwrap gives http.NewResponseController access to the original writer,
+// e.g. to flush a streamed export or push back its deadlines
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
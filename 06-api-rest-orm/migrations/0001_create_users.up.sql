@@ -0,0 +1,7 @@
+-- Users of the API, formerly created by AutoMigrate from models.User
+CREATE TABLE IF NOT EXISTS users (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	email VARCHAR(50),
+	role VARCHAR(10) NOT NULL DEFAULT 'user');
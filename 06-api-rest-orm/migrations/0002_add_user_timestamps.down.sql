@@ -0,0 +1,4 @@
+DROP INDEX idx_users_deleted_at ON users;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
@@ -0,0 +1,5 @@
+-- Creation, update and soft deletion times of the users, managed by GORM
+ALTER TABLE users ADD COLUMN created_at DATETIME(3) NULL;
+ALTER TABLE users ADD COLUMN updated_at DATETIME(3) NULL;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME(3) NULL;
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,2 @@
+-- Version of the users, incremented on every save for optimistic concurrency (ETag / If-Match)
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+-- Time the users proved they own their email address, NULL until then
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL DEFAULT NULL;
+-- One-time tokens sent by email to verify an address or reset a password.
+-- Only the ID (jti) of the signed tokens is stored, so each can be used once.
+CREATE TABLE IF NOT EXISTS user_tokens (
+	id VARCHAR(64) PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	purpose VARCHAR(20) NOT NULL,
+	email VARCHAR(50) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL DEFAULT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);
+CREATE INDEX user_tokens_user_id ON user_tokens (user_id);
+CREATE INDEX user_tokens_expires_at ON user_tokens (expires_at);
@@ -0,0 +1,18 @@
+// Package migrations holds the versioned SQL migrations of the database schema,
+// applied with the "migrate" command of the program.
+package migrations
+
+import "embed"
+
+// Dir is the directory of the migration files, relative to the project.
+// "migrate create" adds the new files there.
+const Dir = "migrations"
+
+// Table records the applied migrations. It is named after the program, so
+// other programs migrating the same database keep their own history.
+const Table = "schema_migrations_gorm"
+
+// Files holds the migration files, embedded in the program
+//
+//go:embed *.sql
+var Files embed.FS
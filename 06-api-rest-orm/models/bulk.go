@@ -0,0 +1,123 @@
+package models
+
+import (
+	"apperr"
+	"context"
+	"gorm/db"
+	"strconv"
+	"time"
+	"validation"
+
+	"gorm.io/gorm"
+)
+
+// Formats of the bulk import and export of users
+const (
+	FormatCSV    = "csv"    // Comma separated values with a header line
+	FormatNDJSON = "ndjson" // Newline delimited JSON, one user object per line
+)
+
+// ImportBatchSize is the number of users inserted by every transaction of an import
+const ImportBatchSize = 500
+
+// ImportColumns are the columns accepted in the header of a CSV import, role is optional
+var ImportColumns = []string{"username", "password", "email", "role"}
+
+// ExportColumns are the columns of a CSV export, in the order of User.Record
+var ExportColumns = []string{"id", "username", "email", "role", "created_at", "updated_at", "deleted_at", "version"}
+
+// ImportReport is the result of a bulk import, sent as the response
+type ImportReport struct {
+	Imported int        `json:"imported"` // Number of users saved
+	Failed   int        `json:"failed"`   // Number of rows that were not saved
+	Errors   []RowError `json:"errors"`   // Why each failed row was not saved
+}
+
+// RowError lists the problems of a row that could not be imported
+type RowError struct {
+	Line   int               `json:"line"`   // Line of the row in the body, starting at 1
+	Errors validation.Errors `json:"errors"` // Invalid fields of the row, "row" when the row as a whole failed
+}
+
+// NewImportReport returns an empty report
+func NewImportReport() *ImportReport {
+	return &ImportReport{Errors: []RowError{}}
+}
+
+// Fail records that the row at the given line was not imported
+func (report *ImportReport) Fail(line int, errs validation.Errors) {
+	report.Failed++
+	report.Errors = append(report.Errors, RowError{Line: line, Errors: errs})
+}
+
+// Record returns the user as a CSV record with the columns of ExportColumns.
+// The password is never exported.
+func (user *User) Record() []string {
+	deletedAt := ""
+	if user.DeletedAt.Valid {
+		deletedAt = user.DeletedAt.Time.Format(time.RFC3339)
+	}
+
+	return []string{
+		strconv.FormatInt(user.Id, 10),
+		user.Username,
+		user.Email,
+		user.Role,
+		user.CreatedAt.Format(time.RFC3339),
+		user.UpdatedAt.Format(time.RFC3339),
+		deletedAt,
+		strconv.FormatInt(user.Version, 10),
+	}
+}
+
+// ImportUsers inserts new users in a single transaction: either every user is
+// saved, and gets its ID, or none is
+func ImportUsers(ctx context.Context, users Users) error {
+	// Hash the passwords first, so the transaction is not kept open while hashing
+	for i := range users {
+		if err := users[i].HashPassword(); err != nil {
+			return apperr.Internal(err)
+		}
+		users[i].Version = 1
+	}
+
+	err := db.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&users).Error
+	})
+	if err != nil {
+		return writeError(err)
+	}
+
+	return nil
+}
+
+// ExportUsers calls fn with every active user, or every soft deleted user when
+// deleted is true, sorted by ID. The users are read one row at a time so they
+// are never all held in memory. It stops at the first error returned by fn.
+func ExportUsers(ctx context.Context, deleted bool, fn func(User) error) error {
+	tx := db.Database.WithContext(ctx).Model(&User{}).Order("id")
+	if deleted {
+		tx = tx.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+
+	rows, err := tx.Rows()
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user := User{}
+		if err := tx.ScanRows(rows, &user); err != nil {
+			return apperr.Internal(err)
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
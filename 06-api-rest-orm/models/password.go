@@ -0,0 +1,91 @@
+package models
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// PasswordCost is the bcrypt cost used to hash new passwords.
+// Hashes created with a lower cost are upgraded the next time the user logs in.
+const PasswordCost = bcrypt.DefaultCost
+
+// isHashed reports whether the password already holds a bcrypt hash
+func isHashed(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+// HashPassword replaces the plaintext password of the user with its bcrypt hash.
+// Only the hash read from the store is left untouched, so it is safe to call
+// it every time the user is saved. Any other value is hashed, even one shaped
+// like a bcrypt hash: clients cannot choose the hash stored for a user.
+func (user *User) HashPassword() error {
+	if user.Password == user.storedPassword && isHashed(user.Password) {
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), PasswordCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hash)
+	user.storedPassword = user.Password
+	return nil
+}
+
+// KeepPassword gives the user the password of current, as read from the
+// store, e.g. when an update does not send a new one. HashPassword leaves it
+// untouched.
+func (user *User) KeepPassword(current User) {
+	user.Password, user.storedPassword = current.Password, current.storedPassword
+}
+
+// BeforeSave is a GORM hook that runs before every insert or update.
+// It hashes the password and gives the default role to users without one.
+func (user *User) BeforeSave(tx *gorm.DB) error {
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+
+	return user.HashPassword()
+}
+
+// AfterFind is a GORM hook that runs after every query reading users.
+// It records the password hash read, so HashPassword does not hash it again.
+func (user *User) AfterFind(tx *gorm.DB) error {
+	user.storedPassword = user.Password
+	return nil
+}
+
+// VerifyPassword reports whether the plaintext password matches the stored one.
+// Rows created before passwords were hashed still hold plaintext, those are
+// compared in constant time and reported by NeedsRehash.
+func (user *User) VerifyPassword(password string) bool {
+	if !isHashed(user.Password) {
+		return subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+// NeedsRehash reports whether the stored password is plaintext or was hashed
+// with a lower cost than PasswordCost. After a successful VerifyPassword the
+// caller should store the plaintext again so it gets hashed with the current cost.
+func (user *User) NeedsRehash() bool {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	return err != nil || cost < PasswordCost
+}
+
+// MarshalJSON serializes the user without its password, so neither the
+// plaintext nor the hash is ever sent back to clients
+func (user User) MarshalJSON() ([]byte, error) {
+	// publicUser has the same fields as User but not its MarshalJSON method
+	type publicUser User
+	public := publicUser(user)
+	public.Password = ""
+	return json.Marshal(public)
+}
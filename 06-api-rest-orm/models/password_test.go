@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestHashPassword checks that every password sent by clients is hashed, even
+// one shaped like a bcrypt hash, while the hash read from the database is kept
+func TestHashPassword(t *testing.T) {
+	chosen, err := bcrypt.GenerateFromPassword([]byte("password1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A hash sent by a client is hashed like any other password
+	user := User{Password: string(chosen)}
+	if err := user.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if user.Password == string(chosen) || !user.VerifyPassword(string(chosen)) {
+		t.Errorf("Incorrect password, the hash sent was kept as is")
+	}
+
+	// The hash read from the database is kept, also by users it is given to
+	stored := User{Password: string(chosen)}
+	stored.AfterFind(nil)
+	changes := User{}
+	changes.KeepPassword(stored)
+	for _, user := range []*User{&stored, &changes} {
+		if err := user.HashPassword(); err != nil {
+			t.Fatal(err)
+		}
+		if user.Password != string(chosen) {
+			t.Errorf("Incorrect password, got %s, expected the stored hash %s", user.Password, chosen)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"apperr"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"gorm/db"
+	"time"
+	"validation"
+)
+
+// Token is a one-time token sent to a user by email, e.g. to verify their
+// address. The signed token itself only travels by email, the user_tokens
+// table keeps its ID so it can be used once and revoked when a newer one is sent.
+type Token struct {
+	Id        string     `gorm:"size:64;primaryKey"` // ID of the token, the jti claim of the signed token
+	UserId    int64      `gorm:"not null;index"`     // User the token was issued for
+	Purpose   string     `gorm:"size:20;not null"`   // Kind of the signed token, e.g. auth.VerifyEmailToken
+	Email     string     `gorm:"size:50;not null"`   // Address the token was sent to
+	ExpiresAt time.Time  `gorm:"not null;index"`     // When the token stops being accepted
+	UsedAt    *time.Time // When the token was used or revoked, nil until then
+	CreatedAt time.Time  // When the token was issued, set by GORM
+}
+
+// TableName tells GORM the table of the tokens, see migration 0005_add_user_tokens
+func (Token) TableName() string {
+	return "user_tokens"
+}
+
+// ErrInvalidToken is returned when a token sent by email is malformed,
+// expired, already used or revoked. It is sent to clients as a 422.
+var ErrInvalidToken = apperr.Validation(validation.Errors{{Field: "token", Reason: "is invalid or expired"}})
+
+// NewToken creates an unused token of the given purpose for the user, sent to
+// their current address and valid for ttl, with a random ID of 32 hexadecimal characters
+func NewToken(user User, purpose string, ttl time.Duration) Token {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+
+	return Token{Id: hex.EncodeToString(buf), UserId: user.Id, Purpose: purpose, Email: user.Email, ExpiresAt: time.Now().Add(ttl)}
+}
+
+// Save inserts the token, after removing the tokens that expired so the table
+// only holds the ones still worth checking
+func (token *Token) Save(ctx context.Context) error {
+	tx := db.Database.WithContext(ctx)
+	if err := tx.Where("expires_at <= ?", time.Now()).Delete(&Token{}).Error; err != nil {
+		return apperr.Internal(err)
+	}
+	if err := tx.Create(token).Error; err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
+
+// UseToken marks the unused and unexpired token with the given ID and purpose
+// as used and returns it, or ErrInvalidToken. The update only matches an
+// unused token, so only one request can use it.
+func UseToken(ctx context.Context, id, purpose string) (Token, error) {
+	tx := db.Database.WithContext(ctx)
+	now := time.Now()
+	result := tx.Model(&Token{}).Where("id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", id, purpose, now).Update("used_at", now)
+	if result.Error != nil {
+		return Token{}, apperr.Internal(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return Token{}, ErrInvalidToken
+	}
+
+	token := Token{}
+	if err := tx.Where("id = ?", id).First(&token).Error; err != nil {
+		return Token{}, apperr.Internal(err)
+	}
+
+	return token, nil
+}
+
+// RevokeTokens marks every unused token of the user with the given purpose as
+// used, e.g. once a newer one is sent
+func RevokeTokens(ctx context.Context, userId int64, purpose string) error {
+	err := db.Database.WithContext(ctx).Model(&Token{}).Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).Update("used_at", time.Now()).Error
+	if err != nil {
+		return apperr.Internal(err)
+	}
+
+	return nil
+}
@@ -1,24 +1,323 @@
-package models
-
-import (
-	"gorm/db"
-)
-
-// User struct represents a user entity in the database.
-// Each User has an ID, Username, Password, and Email fields.
-type User struct {
-	Id       int64  `json:"id"`       // Unique identifier for the user
-	Username string `json:"username"` // Username of the user
-	Password string `json:"password"` // Password for the user (should be hashed in real applications)
-	Email    string `json:"email"`    // Email address of the user
-}
-
-// Users type represents a collection (or list) of User entities.
-type Users []User
-
-// MigrateUser function automatically migrates the User model to the database.
-// It creates or updates the 'users' table in the database based on the User struct's definition.
-func MigrateUser() {
-	// Call AutoMigrate to automatically create or update the User table.
-	db.Database.AutoMigrate(User{})
-}
+package models
+
+import (
+	"apperr"
+	"context"
+	"errors"
+	"fmt"
+	"gorm/db"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"validation"
+
+	"gorm.io/gorm"
+)
+
+// User struct represents a user entity in the database.
+// Each User has an ID, Username, Password, and Email fields.
+// The validate tags are checked by Validate before the user is saved.
+type User struct {
+	Id       int64  `json:"id"`                                                                                     // Unique identifier for the user
+	Username string `json:"username" gorm:"size:30;not null;uniqueIndex:users_username" validate:"required,max=30"` // Username of the user
+	Password string `json:"password,omitempty" gorm:"size:100;not null" validate:"required,min=8,max=72"`           // Bcrypt hash of the password, never serialized
+	Email    string `json:"email" gorm:"size:50;uniqueIndex:users_email" validate:"required,email,max=50"`          // Email address of the user
+	Role     string `json:"role" gorm:"size:10;not null;default:user" validate:"oneof=admin user"`                  // RoleAdmin or RoleUser
+
+	// Set by GORM, the values sent by clients are ignored
+	CreatedAt       time.Time      `json:"created_at"`              // When the user was created
+	UpdatedAt       time.Time      `json:"updated_at"`              // When the user was last saved
+	DeletedAt       gorm.DeletedAt `json:"deleted_at" gorm:"index"` // When the user was soft deleted, null for active users
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`       // When the user proved they own the email, null until then
+	Version         int64          `json:"version" gorm:"not null"` // Incremented on every save, see ETag
+
+	storedPassword string // Password as read from the database, see HashPassword
+}
+
+// Roles a user can have
+const (
+	RoleAdmin = "admin" // Can list, update and delete every user
+	RoleUser  = "user"  // Can only read and update their own record
+)
+
+// IsAdmin reports whether the user has the admin role
+func (user *User) IsAdmin() bool {
+	return user.Role == RoleAdmin
+}
+
+// IsEmailVerified reports whether the user proved they own their email address
+func (user *User) IsEmailVerified() bool {
+	return user.EmailVerifiedAt != nil
+}
+
+// Users type represents a collection (or list) of User entities.
+type Users []User
+
+// ErrNotFound is returned when the requested user does not exist.
+// It is of kind apperr.ErrNotFound, so it is sent to clients as a 404.
+var ErrNotFound = apperr.NotFound("User not found")
+
+// ErrVersionMismatch is returned when a user was changed since the version the
+// request is based on was read. It is sent to clients as a 412.
+var ErrVersionMismatch = apperr.PreconditionFailed("The user was changed by another request, fetch it again")
+
+// ETag returns the entity tag of the current version of the user, e.g. "3",
+// sent in the ETag header and compared with the If-Match header of requests
+func (user *User) ETag() string {
+	return strconv.Quote(strconv.FormatInt(user.Version, 10))
+}
+
+// MatchesETag reports whether the If-Match header allows changing the user:
+// it lists the ETag of the user or is "*". An empty header always matches.
+func (user *User) MatchesETag(ifMatch string) bool {
+	if ifMatch == "" {
+		return true
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == user.ETag() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnsureAdmin creates an admin with the given credentials when no user with
+// that username exists, so a fresh database always has someone able to
+// manage the other users. The admin is validated like any other user.
+// Deleted users keep their username until they are purged: a deleted admin
+// is restored, any other deleted user is left alone.
+func EnsureAdmin(ctx context.Context, username, password, email string) error {
+	// Look at the deleted users too, the insert would fail on their username
+	user, err := findUserWhere(db.Database.WithContext(ctx).Unscoped(), "username = ?", username)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		admin := User{Username: username, Password: password, Email: email, Role: RoleAdmin}
+		if err := admin.Validate(ctx); err != nil {
+			// Name the invalid or taken fields, the message alone does not
+			var appErr *apperr.Error
+			if errors.As(err, &appErr) && len(appErr.Fields) > 0 {
+				err = fmt.Errorf("%w: %v", err, appErr.Fields)
+			}
+			return fmt.Errorf("invalid admin %s: %w", username, err)
+		}
+		return admin.Save(ctx)
+	case err != nil:
+		return err
+	case !user.DeletedAt.Valid:
+		return nil
+	case user.Role != RoleAdmin:
+		slog.Warn("Admin not created, a deleted user has its username", "username", username, "id", user.Id)
+		return nil
+	}
+
+	if err := user.ValidateUnique(ctx); err != nil {
+		return fmt.Errorf("restoring the admin %s: %w", username, err)
+	}
+	slog.Info("Restoring the deleted admin", "username", username, "id", user.Id)
+	return user.Restore(ctx)
+}
+
+// AllUsers returns every active user in the database, soft deleted users are left out by GORM.
+// Like every function below, it stops waiting for the database when ctx is canceled.
+func AllUsers(ctx context.Context) (Users, error) {
+	users := Users{}
+	if err := db.Database.WithContext(ctx).Find(&users).Error; err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	return users, nil
+}
+
+// DeletedUsers returns every soft deleted user in the database
+func DeletedUsers(ctx context.Context) (Users, error) {
+	users := Users{}
+	if err := db.Database.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Find(&users).Error; err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	return users, nil
+}
+
+// FindUser returns the active user with the given ID, or ErrNotFound
+func FindUser(ctx context.Context, id int64) (User, error) {
+	return findUserWhere(db.Database.WithContext(ctx), "id = ?", id)
+}
+
+// FindUserByUsername returns the active user with the given username, or ErrNotFound
+func FindUserByUsername(ctx context.Context, username string) (User, error) {
+	return findUserWhere(db.Database.WithContext(ctx), "username = ?", username)
+}
+
+// FindUserByEmail returns the active user with the given email, or ErrNotFound
+func FindUserByEmail(ctx context.Context, email string) (User, error) {
+	return findUserWhere(db.Database.WithContext(ctx), "email = ?", email)
+}
+
+// FindDeletedUser returns the soft deleted user with the given ID, or ErrNotFound
+func FindDeletedUser(ctx context.Context, id int64) (User, error) {
+	return findUserWhere(db.Database.WithContext(ctx).Unscoped(), "id = ? AND deleted_at IS NOT NULL", id)
+}
+
+// findUserWhere returns the first user matching the condition, translating
+// gorm.ErrRecordNotFound into ErrNotFound and every other error into an internal one
+func findUserWhere(tx *gorm.DB, condition string, args ...interface{}) (User, error) {
+	user := User{}
+	err := tx.Where(condition, args...).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, ErrNotFound
+	} else if err != nil {
+		return user, apperr.Internal(err)
+	}
+
+	return user, nil
+}
+
+// Save inserts the user when its ID is 0, otherwise updates it. Updates only
+// succeed when the stored version is still user.Version, otherwise they return
+// ErrVersionMismatch. The version is incremented on every save.
+func (user *User) Save(ctx context.Context) error {
+	tx := db.Database.WithContext(ctx)
+	if user.Id == 0 {
+		user.Version = 1
+		if err := tx.Create(user).Error; err != nil {
+			return writeError(err)
+		}
+		return nil
+	}
+
+	// Write every column but the creation and deletion times, GORM sets the
+	// update time and leaves soft deleted users out
+	version := user.Version
+	user.Version++
+	result := tx.Model(user).Where("version = ?", version).Select("*").Omit("created_at", "deleted_at").Updates(user)
+	if result.Error != nil || result.RowsAffected == 0 {
+		user.Version = version
+	}
+	if result.Error != nil {
+		return writeError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Tell a missing user from one changed by another request
+		if _, err := findUserWhere(tx, "id = ?", user.Id); err != nil {
+			return err
+		}
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// Delete soft deletes the user: it sets its DeletedAt, so GORM leaves it out
+// of the queries until Restore is called. Like Save, it only succeeds when the
+// stored version is still user.Version, otherwise it returns ErrVersionMismatch.
+func (user *User) Delete(ctx context.Context) error {
+	now := time.Now()
+	tx := db.Database.WithContext(ctx)
+	result := tx.Model(user).Where("version = ?", user.Version).UpdateColumns(map[string]interface{}{"deleted_at": now, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return apperr.Internal(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Tell a missing user from one changed by another request
+		if _, err := findUserWhere(tx, "id = ?", user.Id); err != nil {
+			return err
+		}
+		return ErrVersionMismatch
+	}
+
+	user.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	user.Version++
+	return nil
+}
+
+// Restore brings back the soft deleted user, or returns ErrNotFound
+func (user *User) Restore(ctx context.Context) error {
+	result := db.Database.WithContext(ctx).Unscoped().Model(user).Where("deleted_at IS NOT NULL").Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return writeError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version++
+	return nil
+}
+
+// Purge removes the user from the database for good, deleted or not, or returns ErrNotFound
+func (user *User) Purge(ctx context.Context) error {
+	result := db.Database.WithContext(ctx).Unscoped().Delete(user)
+	if result.Error != nil {
+		return apperr.Internal(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UniqueColumns are the columns no two users can share, soft deleted users
+// included until they are purged. The users table has a unique index on each
+// of them, named "users_" followed by the column, see the migrations.
+var UniqueColumns = []string{"username", "email"}
+
+// Validate checks the user against the rules of its validate tags and makes
+// sure no other user already has the same username or email.
+// It returns nil when the user can be saved, an apperr.ErrValidation error
+// listing the invalid fields, or an apperr.ErrConflict error naming the taken
+// ones, see ValidateUnique.
+func (user *User) Validate(ctx context.Context) error {
+	if errs := validation.Struct(user); len(errs) > 0 {
+		return apperr.Validation(errs)
+	}
+
+	return user.ValidateUnique(ctx)
+}
+
+// ValidateUnique only makes sure no other user, active or soft deleted, has the
+// same username or email, e.g. before restoring a soft deleted user. The taken
+// fields are returned as an apperr.Duplicate error, sent to clients as a 409.
+// The unique indexes still catch two requests racing for a value, see writeError.
+func (user *User) ValidateUnique(ctx context.Context) error {
+	unique := map[string]string{"username": user.Username, "email": user.Email}
+	taken := []string{}
+	for _, column := range UniqueColumns {
+		var count int64
+		err := db.Database.WithContext(ctx).Unscoped().Model(&User{}).Where(column+" = ? AND id <> ?", unique[column], user.Id).Count(&count).Error
+		if err != nil {
+			return apperr.Internal(err)
+		}
+		if count > 0 {
+			taken = append(taken, column)
+		}
+	}
+
+	if len(taken) > 0 {
+		return apperr.Duplicate(nil, taken...)
+	}
+
+	return nil
+}
+
+// writeError translates the error of a statement writing users. A username or
+// email refused by a unique index is an apperr.Duplicate error naming the field,
+// sent to clients as a 409, anything else is an internal error.
+func writeError(err error) error {
+	index, ok := db.DuplicateKey(err)
+	if !ok {
+		return apperr.Internal(err)
+	}
+
+	column := strings.TrimPrefix(index, "users_")
+	if !slices.Contains(UniqueColumns, column) {
+		return apperr.Duplicate(err)
+	}
+
+	return apperr.Duplicate(err, column)
+}
@@ -0,0 +1,43 @@
+// Package openapi serves the OpenAPI 3 description of the user API together
+// with a Swagger UI to browse it and try the requests.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/swaggest/swgui/v5emb"
+)
+
+// Paths where the document and the Swagger UI are served
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// docsPolicy is the Content-Security-Policy of the Swagger UI, its page runs
+// inline scripts and styles next to the embedded assets
+const docsPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"
+
+// Spec is the OpenAPI 3 document describing the routes of /api/user/.
+// main_test.go checks that the handlers and the models behave as it says.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// ServeSpec sends the OpenAPI document
+func ServeSpec(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(Spec)
+}
+
+// Docs returns the handler of the Swagger UI showing the document served at
+// SpecPath. Its assets are embedded in the binary, so it works offline.
+// It must receive every request under DocsPath.
+func Docs() http.Handler {
+	ui := v5emb.New("User API", SpecPath, DocsPath)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Security-Policy", docsPolicy)
+		ui.ServeHTTP(rw, r)
+	})
+}
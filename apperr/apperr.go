@@ -0,0 +1,228 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"validation"
+)
+
+// Sentinel errors describing the kind of failure. Use errors.Is to check the
+// kind of an error returned by the model layer.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrTimeout      = errors.New("timeout")
+	ErrCanceled     = errors.New("canceled")
+	ErrInternal     = errors.New("internal error")
+
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrTooManyRequests      = errors.New("too many requests")
+	ErrTooLarge             = errors.New("request too large")
+)
+
+// StatusClientClosedRequest is the status of the requests the client gave up
+// on before the response, from nginx. No response reaches the client, it only
+// shows in the logs and the metrics.
+const StatusClientClosedRequest = 499
+
+// kinds maps every sentinel to its HTTP status and machine readable code
+var kinds = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ErrBadRequest, http.StatusBadRequest, "bad_request"},
+	{ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{ErrForbidden, http.StatusForbidden, "forbidden"},
+	{ErrNotFound, http.StatusNotFound, "not_found"},
+	{ErrConflict, http.StatusConflict, "conflict"},
+	{ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed"},
+	{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{ErrTooLarge, http.StatusRequestEntityTooLarge, "request_too_large"},
+	{ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	{ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests"},
+	{ErrCanceled, StatusClientClosedRequest, "canceled"},
+	{ErrTimeout, http.StatusGatewayTimeout, "timeout"},
+	{ErrInternal, http.StatusInternalServerError, "internal_error"},
+}
+
+// Error is an error of a given kind with a message safe to show to clients
+type Error struct {
+	Kind    error             // One of the sentinel errors
+	Message string            // Message sent to the client
+	Fields  validation.Errors // Invalid fields for ErrValidation, taken ones for Duplicate
+	Err     error             // Underlying cause, never sent to the client
+}
+
+// Error returns the message, followed by the cause when there is one
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+// Unwrap lets errors.Is and errors.As see both the kind and the cause
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+
+	return []error{e.Kind}
+}
+
+// New creates an Error of the given kind with a message for the client
+func New(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// BadRequest creates an ErrBadRequest error with the given message
+func BadRequest(message string) *Error {
+	return New(ErrBadRequest, message)
+}
+
+// Unauthorized creates an ErrUnauthorized error with the given message
+func Unauthorized(message string) *Error {
+	return New(ErrUnauthorized, message)
+}
+
+// Forbidden creates an ErrForbidden error with a default message
+func Forbidden() *Error {
+	return New(ErrForbidden, "Permission denied")
+}
+
+// NotFound creates an ErrNotFound error with the given message
+func NotFound(message string) *Error {
+	return New(ErrNotFound, message)
+}
+
+// Conflict creates an ErrConflict error with the given message
+func Conflict(message string) *Error {
+	return New(ErrConflict, message)
+}
+
+// Duplicate creates an ErrConflict error for values already used by another
+// record, naming the fields they were given for, e.g. a username stored twice.
+// The cause, e.g. the error of a unique index, is kept for the logs.
+func Duplicate(err error, fields ...string) *Error {
+	message := "A unique value is already taken"
+	switch len(fields) {
+	case 0:
+	case 1:
+		message = "The " + fields[0] + " is already taken"
+	default:
+		message = "The " + strings.Join(fields, " and ") + " are already taken"
+	}
+
+	errs := validation.Errors{}
+	for _, field := range fields {
+		errs.Add(field, "is already taken")
+	}
+
+	return &Error{Kind: ErrConflict, Message: message, Fields: errs, Err: err}
+}
+
+// PreconditionFailed creates an ErrPreconditionFailed error with the given message,
+// e.g. when the If-Match header of a request does not match the current version
+func PreconditionFailed(message string) *Error {
+	return New(ErrPreconditionFailed, message)
+}
+
+// UnsupportedMediaType creates an ErrUnsupportedMediaType error with the given message
+func UnsupportedMediaType(message string) *Error {
+	return New(ErrUnsupportedMediaType, message)
+}
+
+// TooLarge creates an ErrTooLarge error with the given message, e.g. when the
+// body of a request is over the size allowed for its route
+func TooLarge(message string) *Error {
+	return New(ErrTooLarge, message)
+}
+
+// TooManyRequests creates an ErrTooManyRequests error with the given message,
+// e.g. when a client goes over the rate limit of a route
+func TooManyRequests(message string) *Error {
+	return New(ErrTooManyRequests, message)
+}
+
+// Validation creates an ErrValidation error listing the invalid fields
+func Validation(fields validation.Errors) *Error {
+	return &Error{Kind: ErrValidation, Message: "Validation failed", Fields: fields}
+}
+
+// Timeout wraps an error caused by a deadline that passed, e.g. a slow query
+func Timeout(err error) *Error {
+	return &Error{Kind: ErrTimeout, Message: "The request took too long", Err: err}
+}
+
+// Canceled wraps an error caused by the client going away, e.g. a query
+// stopped because the client closed the connection
+func Canceled(err error) *Error {
+	return &Error{Kind: ErrCanceled, Message: "The request was canceled", Err: err}
+}
+
+// Internal wraps an unexpected error. The cause is kept for the logs but the
+// client only gets a generic message.
+func Internal(err error) *Error {
+	return &Error{Kind: ErrInternal, Message: "Internal server error", Err: err}
+}
+
+// From returns err as an *Error. Errors caused by a deadline that passed are
+// timeouts and errors caused by the client going away are cancellations, even
+// when they were wrapped as internal errors, and errors of unknown kind are
+// internal.
+func From(err error) *Error {
+	if errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrTimeout) {
+		return Timeout(err)
+	}
+	if errors.Is(err, context.Canceled) && !errors.Is(err, ErrCanceled) {
+		return Canceled(err)
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	return Internal(err)
+}
+
+// Status returns the HTTP status matching the kind of err
+func Status(err error) int {
+	for _, kind := range kinds {
+		if errors.Is(err, kind.err) {
+			return kind.status
+		}
+	}
+
+	return http.StatusInternalServerError
+}
+
+// StatusText returns the text of an HTTP status, including
+// StatusClientClosedRequest that net/http does not know
+func StatusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+
+	return http.StatusText(status)
+}
+
+// Code returns the machine readable code matching the kind of err, e.g. "not_found"
+func Code(err error) string {
+	for _, kind := range kinds {
+		if errors.Is(err, kind.err) {
+			return kind.code
+		}
+	}
+
+	return "internal_error"
+}
@@ -0,0 +1,15 @@
+module apperr
+
+go 1.23.2
+
+require (
+	logging v0.0.0
+	validation v0.0.0
+)
+
+require github.com/gorilla/mux v1.8.1 // indirect
+
+replace (
+	logging => ../logging
+	validation => ../validation
+)
@@ -0,0 +1,112 @@
+package apperr
+
+import (
+	"encoding/json"
+	"fmt"
+	"logging"
+	"net/http"
+	"strings"
+	"validation"
+)
+
+// RequestIdHeader is the header carrying the ID of a request, see logging.Middleware
+const RequestIdHeader = logging.RequestIdHeader
+
+// problemContentType is the content type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// body is the JSON representation of an error sent to clients
+type body struct {
+	Status    int               `json:"status"`           // HTTP status code
+	Code      string            `json:"code"`             // Machine readable error code
+	Message   string            `json:"message"`          // Message safe to show to clients
+	RequestId string            `json:"request_id"`       // ID of the request
+	Errors    validation.Errors `json:"errors,omitempty"` // Invalid fields, only set when validation fails
+}
+
+// problem is the RFC 7807 representation of an error, sent instead of body to
+// clients that accept "application/problem+json"
+type problem struct {
+	Type      string            `json:"type"`             // URI identifying the kind of problem
+	Title     string            `json:"title"`            // Short summary of the kind of problem
+	Status    int               `json:"status"`           // HTTP status code
+	Detail    string            `json:"detail"`           // Explanation of this occurrence of the problem
+	Instance  string            `json:"instance"`         // Path of the request that failed
+	Code      string            `json:"code"`             // Machine readable error code
+	RequestId string            `json:"request_id"`       // ID of the request
+	Errors    validation.Errors `json:"errors,omitempty"` // Invalid fields, only set when validation fails
+}
+
+// Writer sends the errors of the shared middlewares, such as those of the
+// auth, ratelimit and security packages, to the client. It is Write unless
+// the program sends its errors in a body of its own: it then replaces Writer
+// once, before serving requests.
+var Writer func(rw http.ResponseWriter, r *http.Request, err error) = Write
+
+// Write sends err to the client as a JSON response. The status and code depend
+// on the kind of err, and the body includes the ID of the request. Clients
+// accepting "application/problem+json" get an RFC 7807 problem. Server side
+// errors are logged, since their cause is not sent to the client.
+func Write(rw http.ResponseWriter, r *http.Request, err error) {
+	requestId := RequestId(rw, r)
+	appErr := From(err)
+	status := Status(appErr)
+
+	// Log server side errors, the client only gets a generic message
+	if status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+	} else if status == StatusClientClosedRequest {
+		// Nothing went wrong on the server side, the client went away
+		logging.FromContext(r.Context()).Info("request canceled", "method", r.Method, "path", r.URL.Path)
+	}
+
+	// Ask the client to authenticate with a bearer token
+	if status == http.StatusUnauthorized {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	var output []byte
+	if strings.Contains(r.Header.Get("Accept"), problemContentType) {
+		// Describe the error as an RFC 7807 problem
+		rw.Header().Set("Content-Type", problemContentType)
+		output, _ = json.Marshal(problem{
+			Type:      "about:blank",
+			Title:     StatusText(status),
+			Status:    status,
+			Detail:    appErr.Message,
+			Instance:  r.URL.Path,
+			Code:      Code(appErr),
+			RequestId: requestId,
+			Errors:    appErr.Fields,
+		})
+	} else {
+		// Describe the error with the usual JSON body
+		rw.Header().Set("Content-Type", "application/json")
+		output, _ = json.Marshal(body{
+			Status:    status,
+			Code:      Code(appErr),
+			Message:   appErr.Message,
+			RequestId: requestId,
+			Errors:    appErr.Fields,
+		})
+	}
+
+	rw.WriteHeader(status)
+	fmt.Fprintln(rw, string(output))
+}
+
+// RequestId returns the ID of the request given by logging.Middleware. Without
+// the middleware, it is taken from the X-Request-ID header or generated when
+// the client did not send one. The ID is echoed in the response headers.
+func RequestId(rw http.ResponseWriter, r *http.Request) string {
+	requestId, ok := logging.RequestIdFromContext(r.Context())
+	if !ok {
+		requestId = r.Header.Get(RequestIdHeader)
+	}
+	if requestId == "" {
+		requestId = logging.NewRequestId()
+	}
+
+	rw.Header().Set(RequestIdHeader, requestId)
+	return requestId
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Signing algorithms supported for the tokens
+const (
+	HS256 = "HS256" // HMAC with a shared secret
+	RS256 = "RS256" // RSA with a private key to sign and a public key to verify
+)
+
+// Config holds the settings used to sign and verify tokens
+type Config struct {
+	Algorithm      string        // HS256 or RS256
+	Secret         string        // Shared secret, only used with HS256
+	PrivateKeyFile string        // PEM file with the RSA private key, only used with RS256
+	PublicKeyFile  string        // PEM file with the RSA public key, derived from the private key when empty
+	Issuer         string        // Value of the "iss" claim
+	AccessTTL      time.Duration // Lifetime of access tokens
+	RefreshTTL     time.Duration // Lifetime of refresh tokens
+}
+
+// DefaultConfig returns a Config with HS256, the default token lifetimes and
+// the given issuer, usually the name of the program, e.g. "apirest".
+// The secret or the key files still have to be provided.
+func DefaultConfig(issuer string) Config {
+	return Config{
+		Algorithm:  HS256,
+		Issuer:     issuer,
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 7 * 24 * time.Hour,
+	}
+}
+
+// ConfigFromEnv builds a Config from the JWT_* environment variables,
+// falling back to DefaultConfig(issuer) for the ones that are not set:
+//
+//	JWT_ALGORITHM, JWT_SECRET, JWT_PRIVATE_KEY_FILE, JWT_PUBLIC_KEY_FILE,
+//	JWT_ISSUER, JWT_ACCESS_TTL, JWT_REFRESH_TTL
+func ConfigFromEnv(issuer string) (Config, error) {
+	cfg := DefaultConfig(issuer)
+
+	if value := os.Getenv("JWT_ALGORITHM"); value != "" {
+		cfg.Algorithm = value
+	}
+	if value := os.Getenv("JWT_ISSUER"); value != "" {
+		cfg.Issuer = value
+	}
+	cfg.Secret = os.Getenv("JWT_SECRET")
+	cfg.PrivateKeyFile = os.Getenv("JWT_PRIVATE_KEY_FILE")
+	cfg.PublicKeyFile = os.Getenv("JWT_PUBLIC_KEY_FILE")
+
+	// Parse the token lifetimes, e.g. "15m" or "168h"
+	for name, ttl := range map[string]*time.Duration{
+		"JWT_ACCESS_TTL":  &cfg.AccessTTL,
+		"JWT_REFRESH_TTL": &cfg.RefreshTTL,
+	} {
+		if value := os.Getenv(name); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", name, err)
+			}
+			*ttl = duration
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the Config has everything needed for its algorithm
+func (cfg Config) Validate() error {
+	switch cfg.Algorithm {
+	case HS256:
+		if cfg.Secret == "" {
+			return errors.New("auth: a secret is required to sign HS256 tokens")
+		}
+	case RS256:
+		if cfg.PrivateKeyFile == "" && cfg.PublicKeyFile == "" {
+			return errors.New("auth: a private or public key file is required for RS256 tokens")
+		}
+	default:
+		return fmt.Errorf("auth: unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.AccessTTL <= 0 || cfg.RefreshTTL <= 0 {
+		return errors.New("auth: token lifetimes must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+module auth
+
+go 1.23.2
+
+require (
+	apperr v0.0.0
+	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/gorilla/mux v1.8.1
+)
+
+require (
+	logging v0.0.0 // indirect
+	validation v0.0.0 // indirect
+)
+
+replace (
+	apperr => ../apperr
+	logging => ../logging
+	validation => ../validation
+)
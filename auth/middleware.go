@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"apperr"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Identity describes the authenticated user of a request
+type Identity struct {
+	Id       int64  // ID of the user
+	Username string // Username of the user
+	Role     string // Role of the user, e.g. RoleAdmin
+}
+
+// RoleAdmin is the role of the users allowed to manage every other user, the
+// admin role of the models of each program
+const RoleAdmin = "admin"
+
+// IsAdmin reports whether the authenticated user has the admin role
+func (identity Identity) IsAdmin() bool {
+	return identity.Role == RoleAdmin
+}
+
+// contextKey is the type of the keys stored by this package in a context,
+// so they cannot collide with keys from other packages
+type contextKey int
+
+// identityKey is the context key under which the Identity is stored
+const identityKey contextKey = 0
+
+// WithIdentity returns a copy of ctx carrying the given Identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext returns the Identity stored in ctx, if any
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(Identity)
+	return identity, ok
+}
+
+// Require is a middleware that only lets through requests carrying a valid
+// access token in the "Authorization: Bearer <token>" header. The
+// authenticated user is put into the request context for the next handler.
+func (m *TokenManager) Require(next http.Handler) http.Handler {
+	return m.authenticate(next, true)
+}
+
+// Optional is a middleware that authenticates the request when it carries an
+// access token, but also lets through anonymous requests. Handlers can then
+// check IdentityFromContext to adapt their behavior.
+func (m *TokenManager) Optional(next http.Handler) http.Handler {
+	return m.authenticate(next, false)
+}
+
+// authenticate verifies the bearer token of the request and puts the
+// authenticated user into the request context
+func (m *TokenManager) authenticate(next http.Handler, required bool) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Extract the token from the Authorization header
+		header := r.Header.Get("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			if required {
+				apperr.Writer(rw, r, apperr.Unauthorized("Missing bearer token"))
+			} else {
+				next.ServeHTTP(rw, r)
+			}
+			return
+		}
+
+		// Verify the token, only access tokens are accepted here
+		claims, err := m.Parse(token, AccessToken)
+		if err != nil {
+			apperr.Writer(rw, r, apperr.Unauthorized("Invalid or expired token"))
+			return
+		}
+
+		// Pass the authenticated user to the next handler
+		identity := Identity{Id: claims.UserId(), Username: claims.Username, Role: claims.Role}
+		next.ServeHTTP(rw, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"apperr"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminOnly is a middleware that only lets through requests from admins.
+// It must be placed after Require, which puts the identity into the context.
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if identity, ok := IdentityFromContext(r.Context()); !ok || !identity.IsAdmin() {
+			apperr.Writer(rw, r, apperr.Forbidden())
+			return
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// SelfOrAdmin is a middleware that only lets through requests from admins or
+// from the user whose ID is in the {id} route variable, so users can only
+// reach their own record. It must be placed after Require.
+func SelfOrAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			apperr.Writer(rw, r, apperr.Forbidden())
+			return
+		}
+
+		// Compare the authenticated user with the requested one
+		userId, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if !identity.IsAdmin() && identity.Id != userId {
+			apperr.Writer(rw, r, apperr.Forbidden())
+			return
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
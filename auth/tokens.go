@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Kinds of token issued by a TokenManager
+const (
+	AccessToken  = "access"  // Short lived token sent on every request
+	RefreshToken = "refresh" // Long lived token only used to get a new pair
+
+	VerifyEmailToken   = "verify_email"   // Sent by email to prove the user owns the address, see IssueOneTime
+	ResetPasswordToken = "reset_password" // Sent by email to choose a new password, see IssueOneTime
+)
+
+// ErrInvalidToken is returned when a token is malformed, expired, badly
+// signed or of the wrong kind
+var ErrInvalidToken = errors.New("auth: invalid token")
+
+// Claims are the claims carried by the tokens
+type Claims struct {
+	Username  string `json:"username"`   // Username of the authenticated user
+	Role      string `json:"role"`       // Role of the user when the token was issued
+	TokenType string `json:"token_type"` // AccessToken or RefreshToken
+	jwt.RegisteredClaims
+}
+
+// UserId returns the ID of the user stored in the subject claim
+func (claims *Claims) UserId() int64 {
+	id, _ := strconv.ParseInt(claims.Subject, 10, 64)
+	return id
+}
+
+// TokenPair is the pair of tokens returned by the login endpoint
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // Always "Bearer"
+	ExpiresIn    int64  `json:"expires_in"` // Lifetime of the access token in seconds
+}
+
+// TokenManager signs and verifies the tokens
+type TokenManager struct {
+	cfg       Config            // Settings the manager was created with
+	method    jwt.SigningMethod // Algorithm used to sign
+	signKey   interface{}       // Secret or private key used to sign
+	verifyKey interface{}       // Secret or public key used to verify
+}
+
+// NewTokenManager creates a TokenManager from the given Config, loading the
+// RSA keys from disk when RS256 is used
+func NewTokenManager(cfg Config) (*TokenManager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	manager := &TokenManager{cfg: cfg}
+	switch cfg.Algorithm {
+	case HS256:
+		manager.method = jwt.SigningMethodHS256
+		manager.signKey = []byte(cfg.Secret)
+		manager.verifyKey = []byte(cfg.Secret)
+	case RS256:
+		manager.method = jwt.SigningMethodRS256
+		if err := manager.loadRSAKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	return manager, nil
+}
+
+// loadRSAKeys reads the RSA key files of the Config
+func (m *TokenManager) loadRSAKeys() error {
+	if m.cfg.PrivateKeyFile != "" {
+		pem, err := os.ReadFile(m.cfg.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+		m.signKey = key
+		m.verifyKey = &key.PublicKey
+	}
+
+	// A separate public key lets instances verify tokens without being able to sign them
+	if m.cfg.PublicKeyFile != "" {
+		pem, err := os.ReadFile(m.cfg.PublicKeyFile)
+		if err != nil {
+			return err
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+		m.verifyKey = key
+	}
+
+	return nil
+}
+
+// Issue creates a new pair of access and refresh tokens for the user
+func (m *TokenManager) Issue(userId int64, username, role string) (TokenPair, error) {
+	access, err := m.sign(userId, username, role, AccessToken, "", m.cfg.AccessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refresh, err := m.sign(userId, username, role, RefreshToken, "", m.cfg.RefreshTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.cfg.AccessTTL.Seconds()),
+	}, nil
+}
+
+// IssueOneTime creates a token of the given kind for the user, e.g.
+// VerifyEmailToken, valid for ttl. The id is sent as the jti claim, the caller
+// stores it so the token can only be used once.
+func (m *TokenManager) IssueOneTime(userId int64, tokenType, id string, ttl time.Duration) (string, error) {
+	return m.sign(userId, "", "", tokenType, id, ttl)
+}
+
+// sign creates a single signed token of the given kind, with the given jti claim when it is not empty
+func (m *TokenManager) sign(userId int64, username, role, tokenType, id string, ttl time.Duration) (string, error) {
+	// Only a verifying instance (RS256 with just a public key) has no signing key
+	if m.signKey == nil {
+		return "", errors.New("auth: no key available to sign tokens")
+	}
+
+	now := time.Now()
+	claims := Claims{
+		Username:  username,
+		Role:      role,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Subject:   strconv.FormatInt(userId, 10),
+			Issuer:    m.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+}
+
+// Parse verifies the token and returns its claims when it is valid and of the expected kind
+func (m *TokenManager) Parse(token, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(*jwt.Token) (interface{}, error) { return m.verifyKey, nil },
+		jwt.WithValidMethods([]string{m.method.Alg()}), // Reject "none" and algorithm confusion
+		jwt.WithIssuer(m.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings of the database, of the HTTP server and of the
+// emails sent to users. Programs without a server simply ignore the Server and
+// Mail sections.
+type Config struct {
+	Database Database `yaml:"database"`
+	Server   Server   `yaml:"server"`
+	Mail     Mail     `yaml:"mail"`
+}
+
+// Database holds the settings of the MySQL connection. Either set the whole
+// DSN, or its parts (user, password, host, port, name and params).
+type Database struct {
+	DSN             string        `yaml:"dsn" env:"DB_DSN" flag:"db-dsn" secret:"dsn" usage:"MySQL data source name, overrides the other database settings"`
+	User            string        `yaml:"user" env:"DB_USER" flag:"db-user" usage:"MySQL user"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Host            string        `yaml:"host" env:"DB_HOST" flag:"db-host" usage:"MySQL host"`
+	Port            int           `yaml:"port" env:"DB_PORT" flag:"db-port" usage:"MySQL port"`
+	Name            string        `yaml:"name" env:"DB_NAME" flag:"db-name" usage:"MySQL database"`
+	Params          string        `yaml:"params" env:"DB_PARAMS" usage:"extra DSN parameters, e.g. parseTime=true"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	ConnectRetries  int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES"`
+	RetryDelay      time.Duration `yaml:"retry_delay" env:"DB_RETRY_DELAY"`
+	QueryTimeout    time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" flag:"db-timeout" usage:"maximum duration of a database operation"`
+}
+
+// Server holds the settings of the HTTP server
+type Server struct {
+	Addr            string        `yaml:"addr" env:"ADDR" flag:"addr" required:"true" usage:"address to listen on, e.g. :3000"`
+	Port            int           `yaml:"port" env:"PORT" flag:"port" usage:"port to listen on, overrides the port of addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	DrainDelay      time.Duration `yaml:"drain_delay" env:"HTTP_DRAIN_DELAY"`
+	CertFile        string        `yaml:"cert_file" env:"TLS_CERT_FILE" flag:"tls-cert" usage:"PEM certificate file, enables TLS"`
+	KeyFile         string        `yaml:"key_file" env:"TLS_KEY_FILE" flag:"tls-key" usage:"PEM private key file of the certificate"`
+	CORS            CORS          `yaml:"cors"`
+}
+
+// CORS holds the settings of Cross-Origin Resource Sharing, which lets the
+// pages of other origins, e.g. a browser frontend, call the server.
+// The lists are comma separated, no origin disables CORS.
+type CORS struct {
+	AllowedOrigins   string        `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"origins allowed to call the server from a browser, e.g. https://app.example.com, * for any"`
+	AllowedMethods   string        `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   string        `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// Mail holds the settings of the emails sent to users, e.g. to verify their
+// address or reset their password. The "smtp" driver sends them through the
+// SMTP server of host and port, the "file" driver drops each of them as a file
+// in dir, handy on a development machine, and "memory" keeps them in memory.
+type Mail struct {
+	Driver   string `yaml:"driver" env:"MAIL_DRIVER" flag:"mail" usage:"how emails are sent: smtp, file or memory"`
+	From     string `yaml:"from" env:"MAIL_FROM" usage:"sender address of the emails"`
+	Dir      string `yaml:"dir" env:"MAIL_DIR" usage:"directory where the file driver drops the emails"`
+	Host     string `yaml:"host" env:"SMTP_HOST" usage:"SMTP server of the smtp driver"`
+	Port     int    `yaml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// Default returns the settings used when nothing else is configured: the
+// goweb_db database on the local MySQL server, an HTTP server on port 3000 and
+// emails dropped in the mail directory. The database user still has to be provided.
+func Default() Config {
+	return Config{
+		Database: Database{
+			Host:            "localhost",
+			Port:            3306,
+			Name:            "goweb_db",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectRetries:  5,
+			RetryDelay:      2 * time.Second,
+			QueryTimeout:    10 * time.Second,
+		},
+		Server: Server{
+			Addr:            ":3000",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			CORS: CORS{
+				AllowedMethods: "GET,POST,PUT,PATCH,DELETE",
+				AllowedHeaders: "Authorization,Content-Type,If-Match,X-Request-ID",
+				MaxAge:         10 * time.Minute,
+			},
+		},
+		Mail: Mail{
+			Driver: "file",
+			From:   "no-reply@localhost",
+			Dir:    "mail",
+			Port:   587,
+		},
+	}
+}
+
+// Validate checks that the database can be reached with these settings.
+// It is not part of Load, since some programs can run without a database.
+func (db Database) Validate() error {
+	if db.DSN != "" {
+		return nil
+	}
+	if db.User == "" || db.Host == "" || db.Name == "" {
+		return errors.New("config: set database.dsn (DB_DSN) or database.user (DB_USER), database.host (DB_HOST) and database.name (DB_NAME)")
+	}
+
+	return nil
+}
+
+// DataSourceName returns the DSN, building it from its parts when it is not set,
+// e.g. "user:password@tcp(localhost:3306)/goweb_db?parseTime=true"
+func (db Database) DataSourceName() string {
+	if db.DSN != "" {
+		return db.DSN
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s", db.User, db.Password, net.JoinHostPort(db.Host, strconv.Itoa(db.Port)), db.Name)
+	if db.Params != "" {
+		dsn += "?" + db.Params
+	}
+
+	return dsn
+}
+
+// Address returns the address to listen on, replacing the port of Addr when Port is set
+func (srv Server) Address() string {
+	if srv.Port == 0 {
+		return srv.Addr
+	}
+
+	host, _, _ := net.SplitHostPort(srv.Addr)
+	return net.JoinHostPort(host, strconv.Itoa(srv.Port))
+}
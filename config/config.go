@@ -0,0 +1,270 @@
+// Package config loads the settings shared by the examples of this repository.
+//
+// Settings are read in layers, each one overriding the previous:
+//
+//  1. the defaults already set in the struct passed to Load
+//  2. a YAML (.yaml, .yml) or TOML (.toml) file, given by -config or CONFIG_FILE
+//  3. environment variables
+//  4. command line flags
+//
+// The fields of the struct describe where they come from with tags:
+//
+//	yaml:"name"       key of the field in the file, nested structs are sections
+//	env:"NAME"        environment variable
+//	flag:"name"       command line flag
+//	usage:"text"      help of the flag
+//	required:"true"   Load fails when the field is still empty
+//	secret:"true"     the value is hidden by Redacted, "dsn" only hides the password of a DSN
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable with the path of the configuration file,
+// used when the -config flag is not given
+const FileEnv = "CONFIG_FILE"
+
+// setting is a field of the configuration struct together with its tags
+type setting struct {
+	key   string        // Dotted path of the field in the file, e.g. "database.host"
+	field reflect.Value // Settable value of the field
+	tag   reflect.StructTag
+}
+
+// Load fills cfg, a pointer to a struct holding the defaults, from the
+// configuration file, the environment and the command line.
+//
+// The flags of the settings and the -config flag are added to fs, which is
+// then parsed with args. Programs with flags of their own define them on fs
+// before calling Load, e.g. Load(&cfg, flag.CommandLine, os.Args[1:]).
+func Load(cfg interface{}, fs *flag.FlagSet, args []string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Load needs a pointer to a struct")
+	}
+	settings := collect(v.Elem(), "")
+
+	// Register a flag for every setting that has one. The values are only
+	// kept here, they are applied after the file and the environment.
+	flags := map[*setting]string{}
+	file := fs.String("config", os.Getenv(FileEnv), "configuration file (YAML or TOML)")
+	for i := range settings {
+		s := &settings[i]
+		if name := s.tag.Get("flag"); name != "" {
+			fs.Func(name, usage(s), func(value string) error {
+				flags[s] = value
+				return nil
+			})
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Layer 2: the configuration file
+	if *file != "" {
+		if err := loadFile(*file, settings); err != nil {
+			return err
+		}
+	}
+
+	// Layer 3: the environment
+	for _, s := range settings {
+		name := s.tag.Get("env")
+		if value := os.Getenv(name); name != "" && value != "" {
+			if err := set(s.field, value); err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+		}
+	}
+
+	// Layer 4: the command line, in the order of the struct fields
+	for i := range settings {
+		if value, ok := flags[&settings[i]]; ok {
+			if err := set(settings[i].field, value); err != nil {
+				return fmt.Errorf("config: -%s: %w", settings[i].tag.Get("flag"), err)
+			}
+		}
+	}
+
+	return validate(settings)
+}
+
+// collect returns the settings of every field of the struct, walking into nested structs
+func collect(v reflect.Value, prefix string) []setting {
+	settings := []setting{}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		// Nested structs are sections of the file
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, collect(v.Field(i), key)...)
+			continue
+		}
+		settings = append(settings, setting{key: key, field: v.Field(i), tag: field.Tag})
+	}
+
+	return settings
+}
+
+// usage returns the help of the flag of a setting, naming its environment variable
+func usage(s *setting) string {
+	text := s.tag.Get("usage")
+	if env := s.tag.Get("env"); env != "" {
+		text += " (env " + env + ")"
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// loadFile reads a YAML or TOML file, depending on its extension, and sets
+// the settings found in it. Keys that match no setting are rejected so typos
+// do not go unnoticed.
+func loadFile(path string, settings []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config: %s: unsupported file type %q, use .yaml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	// Index the settings by their dotted key
+	byKey := map[string]reflect.Value{}
+	for _, s := range settings {
+		byKey[s.key] = s.field
+	}
+
+	for key, value := range flatten(values, "") {
+		field, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("config: %s: unknown key %q", path, key)
+		}
+		if err := set(field, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("config: %s: %s: %w", path, key, err)
+		}
+	}
+
+	return nil
+}
+
+// flatten turns nested sections into dotted keys, e.g. {database: {host: x}} into "database.host"
+func flatten(values map[string]interface{}, prefix string) map[string]interface{} {
+	flat := map[string]interface{}{}
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if section, ok := value.(map[string]interface{}); ok {
+			for k, v := range flatten(section, key) {
+				flat[k] = v
+			}
+		} else {
+			flat[key] = value
+		}
+	}
+
+	return flat
+}
+
+// set parses value according to the type of the field and stores it
+func set(field reflect.Value, value string) error {
+	// Durations are written like "10s" or "2m"
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// validate checks that every required setting has a value
+func validate(settings []setting) error {
+	missing := []string{}
+	for _, s := range settings {
+		if s.tag.Get("required") == "true" && s.field.IsZero() {
+			missing = append(missing, describe(s))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("config: missing required settings: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// describe names a setting with every source it can come from, e.g. "database.user (DB_USER)"
+func describe(s setting) string {
+	sources := []string{}
+	if env := s.tag.Get("env"); env != "" {
+		sources = append(sources, env)
+	}
+	if name := s.tag.Get("flag"); name != "" {
+		sources = append(sources, "-"+name)
+	}
+
+	if len(sources) == 0 {
+		return s.key
+	}
+
+	return s.key + " (" + strings.Join(sources, ", ") + ")"
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile creates a configuration file with the given name and content in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// load runs Load on the defaults with a fresh flag set
+func load(args ...string) (Config, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	return cfg, Load(&cfg, fs, args)
+}
+
+// TestLoad checks that every layer overrides the previous one.
+func TestLoad(t *testing.T) {
+	yamlFile := writeFile(t, "app.yaml", "database:\n  host: yaml-host\n  port: 3307\n  user: yaml-user\nserver:\n  write_timeout: 1m\n")
+	tomlFile := writeFile(t, "app.toml", "[database]\nhost = \"toml-host\"\nport = 3308\n")
+
+	// Define a table of test cases with the environment, the arguments and the expected settings.
+	table := []struct {
+		env     map[string]string
+		args    []string
+		host    string
+		port    int
+		user    string
+		timeout time.Duration
+	}{
+		// Defaults only
+		{nil, nil, "localhost", 3306, "", 30 * time.Second},
+		// The file overrides the defaults
+		{nil, []string{"-config", yamlFile}, "yaml-host", 3307, "yaml-user", time.Minute},
+		{map[string]string{FileEnv: tomlFile}, nil, "toml-host", 3308, "", 30 * time.Second},
+		// The environment overrides the file
+		{map[string]string{"DB_HOST": "env-host", "DB_USER": "env-user"}, []string{"-config", yamlFile}, "env-host", 3307, "env-user", time.Minute},
+		// The flags override the environment
+		{map[string]string{"DB_HOST": "env-host"}, []string{"-config", yamlFile, "-db-host", "flag-host", "-db-port", "3309"}, "flag-host", 3309, "yaml-user", time.Minute},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		for name, value := range item.env {
+			t.Setenv(name, value)
+		}
+
+		cfg, err := load(item.args...)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", item.args, err)
+		}
+
+		db := cfg.Database
+		if db.Host != item.host || db.Port != item.port || db.User != item.user || cfg.Server.WriteTimeout != item.timeout {
+			t.Errorf("Incorrect settings for %v %v, got %s:%d %q %s, expected %s:%d %q %s",
+				item.env, item.args, db.Host, db.Port, db.User, cfg.Server.WriteTimeout, item.host, item.port, item.user, item.timeout)
+		}
+
+		// Forget the environment of this case before the next one
+		for name := range item.env {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// TestLoadErrors checks that invalid settings are reported.
+func TestLoadErrors(t *testing.T) {
+	unknown := writeFile(t, "app.yaml", "database:\n  hots: typo\n")
+	badPort := writeFile(t, "app.toml", "[database]\nport = \"abc\"\n")
+	ini := writeFile(t, "app.ini", "host=x\n")
+
+	// Define a table of test cases with the arguments and the expected error.
+	table := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-config", unknown}, `unknown key "database.hots"`},
+		{[]string{"-config", badPort}, `"abc" is not a number`},
+		{[]string{"-config", ini}, `unsupported file type ".ini"`},
+		{[]string{"-config", "missing.yaml"}, "no such file"},
+		{[]string{"-addr", ""}, "missing required settings: server.addr (ADDR, -addr)"},
+		{[]string{"-db-timeout", "soon"}, "-db-timeout"},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		if _, err := load(item.args...); err == nil || !strings.Contains(err.Error(), item.want) {
+			t.Errorf("Incorrect error for %v, got %v, expected it to contain %q", item.args, err, item.want)
+		}
+	}
+}
+
+// TestRedacted checks that secrets never appear in the description of the settings.
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "hunter22"
+	cfg.Database.DSN = "root:s3cret@tcp(db:3306)/goweb_db"
+	cfg.Mail.Password = "m4ilbox"
+
+	got := Redacted(cfg)
+	for _, secret := range []string{"hunter22", "s3cret", "m4ilbox"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Secret %q leaked in %q", secret, got)
+		}
+	}
+	if !strings.Contains(got, "database.dsn=root:********@tcp(db:3306)/goweb_db") {
+		t.Errorf("Incorrect DSN in %q", got)
+	}
+}
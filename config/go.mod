@@ -0,0 +1,8 @@
+module config
+
+go 1.23.2
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// mask replaces the secrets in the output of Redacted
+const mask = "********"
+
+// Redacted describes the settings of cfg, a struct or a pointer to one, as
+// "key=value" pairs that are safe to log: secret values are masked, and only
+// the password of DSN values is.
+func Redacted(cfg interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+
+	pairs := []string{}
+	for _, s := range collect(v, "") {
+		value := fmt.Sprint(s.field.Interface())
+		switch s.tag.Get("secret") {
+		case "true":
+			if value != "" {
+				value = mask
+			}
+		case "dsn":
+			value = RedactDSN(value)
+		}
+		pairs = append(pairs, s.key+"="+value)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// RedactDSN masks the password of a data source name such as
+// "user:password@tcp(localhost:3306)/db", leaving the rest readable
+func RedactDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+
+	user, _, found := strings.Cut(dsn[:at], ":")
+	if !found {
+		return dsn
+	}
+
+	return user + ":" + mask + dsn[at:]
+}
@@ -4,6 +4,21 @@ go 1.23.2
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.8.1 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/joho/godotenv v1.5.1
+)
+
+require (
+	config v0.0.0
+	migrate v0.0.0
+)
+
+replace (
+	config => ../config
+	migrate => ../migrate
 )
@@ -0,0 +1,6 @@
+-- Contacts managed from the menu
+CREATE TABLE IF NOT EXISTS contact (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	email VARCHAR(100) NOT NULL,
+	phone VARCHAR(30) NOT NULL);
@@ -0,0 +1,5 @@
+module logging
+
+go 1.23.2
+
+require github.com/gorilla/mux v1.8.1
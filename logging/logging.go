@@ -0,0 +1,62 @@
+// Package logging writes the logs of the server as JSON lines with log/slog.
+// It tags every request with an ID, see Middleware, and gives the handlers and
+// the db package a logger carrying that ID, so an SQL error can be found
+// next to the request that caused it.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"regexp"
+)
+
+// RequestIdHeader is the header carrying the ID of a request. Clients can send
+// it to correlate their logs with ours, otherwise the server generates one.
+const RequestIdHeader = "X-Request-ID"
+
+// validRequestId matches the IDs accepted from clients, anything else is replaced
+// so the logs and the response headers never carry arbitrary text
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// NewLogger returns a logger writing JSON lines to w
+func NewLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// NewRequestId generates a random request ID of 16 hexadecimal characters
+func NewRequestId() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// contextKey is the type of the keys stored by this package in a context,
+// so they cannot collide with keys from other packages
+type contextKey int
+
+// requestIdKey is the context key under which the request ID is stored
+const requestIdKey contextKey = 0
+
+// WithRequestId returns a copy of ctx carrying the given request ID
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// RequestIdFromContext returns the request ID stored in ctx, if any
+func RequestIdFromContext(ctx context.Context) (string, bool) {
+	requestId, ok := ctx.Value(requestIdKey).(string)
+	return requestId, ok
+}
+
+// FromContext returns the default logger, with the request ID of ctx added
+// to every record when there is one
+func FromContext(ctx context.Context) *slog.Logger {
+	if requestId, ok := RequestIdFromContext(ctx); ok {
+		return slog.Default().With("request_id", requestId)
+	}
+
+	return slog.Default()
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware returns a middleware giving every request an ID and an access log line.
+// The ID is taken from the X-Request-ID header when the client sends a valid
+// one, otherwise it is generated. It is echoed in the response headers and
+// stored in the request context, see RequestIdFromContext and FromContext.
+// Once the response is sent, the method, the template of the route of the
+// router it matches, status, size and latency of the request are logged as
+// JSON with the given logger.
+func Middleware(logger *slog.Logger, router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Assign or propagate the request ID
+			requestId := r.Header.Get(RequestIdHeader)
+			if !validRequestId.MatchString(requestId) {
+				requestId = NewRequestId()
+				r.Header.Set(RequestIdHeader, requestId)
+			}
+			rw.Header().Set(RequestIdHeader, requestId)
+			r = r.WithContext(WithRequestId(r.Context(), requestId))
+
+			// Find the template of the route, e.g. /api/user/{id:[0-9]+}, so the
+			// logs of a route can be grouped whatever the IDs in the path
+			route := ""
+			var match mux.RouteMatch
+			if router.Match(r, &match) && match.Route != nil {
+				route, _ = match.Route.GetPathTemplate()
+			}
+
+			// Log the request once it is done, even when the handler aborts it
+			recorder := &responseRecorder{ResponseWriter: rw}
+			defer func() {
+				aborted := recover()
+				level := slog.LevelInfo
+				if aborted != nil || recorder.status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+				logger.LogAttrs(r.Context(), level, "request",
+					slog.String("request_id", requestId),
+					slog.String("method", r.Method),
+					slog.String("route", route),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", recorder.Status()),
+					slog.Int64("bytes", recorder.bytes),
+					slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.Bool("aborted", aborted != nil),
+				)
+				if aborted != nil {
+					panic(aborted)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// responseRecorder remembers the status and the size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int   // Status sent with WriteHeader, 0 until then
+	bytes  int64 // Number of bytes of the body written so far
+}
+
+// WriteHeader records the status before sending it
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes of the body, the status is 200 when WriteHeader was not called
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(data)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Status returns the status of the response, 200 when nothing was written
+// since net/http sends that once the handler returns
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
+
+// Unwrap gives http.NewResponseController access to the original writer,
+// e.g. to flush a streamed export or push back its deadlines
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestMiddleware checks that requests get an ID and that the access log
+// describes them with their route template.
+func TestMiddleware(t *testing.T) {
+	// Route a handler echoing the request ID it sees in its context
+	router := mux.NewRouter()
+	router.HandleFunc("/api/user/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {
+		requestId, _ := RequestIdFromContext(r.Context())
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(requestId))
+	})
+
+	// Define a table of test cases with a path, the X-Request-ID sent and the expected access log
+	table := []struct {
+		path, requestId string
+		keep            bool // Whether the ID sent is kept
+		route           string
+		status          int
+	}{
+		{"/api/user/7", "abc-123", true, "/api/user/{id:[0-9]+}", http.StatusCreated},
+		{"/api/user/7", "", false, "/api/user/{id:[0-9]+}", http.StatusCreated},
+		{"/api/user/7", "bad id\nforged line", false, "/api/user/{id:[0-9]+}", http.StatusCreated},
+		{"/nowhere", "abc-123", true, "", http.StatusNotFound},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		output := &bytes.Buffer{}
+		handler := Middleware(NewLogger(output), router)(router)
+
+		r := httptest.NewRequest("GET", item.path, nil)
+		if item.requestId != "" {
+			r.Header.Set(RequestIdHeader, item.requestId)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+
+		// The ID is echoed in the response and given to the handler
+		requestId := rw.Header().Get(RequestIdHeader)
+		if item.keep && requestId != item.requestId || !item.keep && (requestId == item.requestId || !validRequestId.MatchString(requestId)) {
+			t.Errorf("Incorrect request ID for %q, got %q", item.requestId, requestId)
+		}
+		if item.status == http.StatusCreated && rw.Body.String() != requestId {
+			t.Errorf("Incorrect request ID in the handler, got %q, expected %q", rw.Body.String(), requestId)
+		}
+
+		// The access log is a single JSON line
+		entry := struct {
+			RequestId string `json:"request_id"`
+			Method    string `json:"method"`
+			Route     string `json:"route"`
+			Status    int    `json:"status"`
+			Bytes     int    `json:"bytes"`
+		}{}
+		if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+			t.Fatalf("Incorrect access log %q: %v", output, err)
+		}
+		if entry.RequestId != requestId || entry.Method != "GET" || entry.Route != item.route || entry.Status != item.status || entry.Bytes != rw.Body.Len() {
+			t.Errorf("Incorrect access log for %s, got %s", item.path, output)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File drops every email as an .eml file in a directory instead of sending it,
+// so the links they carry can be followed on a machine without a mail server.
+// The files can be opened with any mail client.
+type File struct {
+	dir  string // Directory of the files, created when missing
+	from string // Sender of the emails
+}
+
+// NewFile returns a Mailer writing the emails sent from the given address to dir
+func NewFile(dir, from string) *File {
+	return &File{dir: dir, from: from}
+}
+
+// Send writes the message to a new file named after the current time, e.g.
+// 20261018T120000.123456789Z-1a2b3c4d5e6f7a8b.eml, readable by its owner only
+// since the emails carry tokens
+func (m *File) Send(ctx context.Context, msg Message) error {
+	data, err := msg.format(m.from)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + randomId() + ".eml"
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
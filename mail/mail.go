@@ -0,0 +1,95 @@
+// Package mail sends emails to the users, e.g. to verify their address or to
+// reset their password. The handlers only see the Mailer interface, so the
+// messages can go through an SMTP server, be dropped as files on a development
+// machine or be kept in memory for the tests to check what was sent.
+package mail
+
+import (
+	"bytes"
+	"config"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string // Address of the recipient
+	Subject string // Subject of the email
+	Body    string // Plain text content of the email
+}
+
+// Mailer sends emails. Send stops waiting for the mail server when ctx is canceled.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the Mailer of the driver of the given settings: SMTP, File or Memory
+func New(cfg config.Mail) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" {
+			return nil, errors.New("mail: set mail.host (SMTP_HOST) to send emails with the smtp driver")
+		}
+		return NewSMTP(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+	case "file":
+		return NewFile(cfg.Dir, cfg.From), nil
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("mail: unknown driver %q, use smtp, file or memory", cfg.Driver)
+	}
+}
+
+// format returns the message as sent by from, with the headers of RFC 5322
+// and lines ending with CRLF. Addresses and subjects spanning several lines
+// are refused, they would let their author add headers.
+func (msg Message) format(from string) ([]byte, error) {
+	if strings.ContainsAny(from+msg.To+msg.Subject, "\r\n") {
+		return nil, errors.New("mail: line breaks are not allowed in addresses and subjects")
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s@%s>\r\n", randomId(), domain(from))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
+
+// randomId generates a random ID of 16 hexadecimal characters
+func randomId() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// domain returns the domain of an address, e.g. "example.com" for
+// "No reply <no-reply@example.com>", or "localhost" when it has none
+func domain(address string) string {
+	_, domain, ok := strings.Cut(strings.Trim(address, "<> "), "@")
+	if !ok {
+		return "localhost"
+	}
+
+	return strings.TrimRight(domain, ">")
+}
+
+// Make sure every driver implements Mailer
+var (
+	_ Mailer = (*SMTP)(nil)
+	_ Mailer = (*File)(nil)
+	_ Mailer = (*Memory)(nil)
+)
@@ -0,0 +1,191 @@
+package mail
+
+import (
+	"bufio"
+	"config"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFormat checks the headers and line endings of the formatted messages,
+// and that line breaks cannot add headers
+func TestFormat(t *testing.T) {
+	// Define a table of test cases with a message and whether it can be formatted
+	table := []struct {
+		msg Message
+		ok  bool
+	}{
+		{Message{To: "alex@example.com", Subject: "Hello", Body: "Line 1\nLine 2\r\n"}, true},
+		{Message{To: "alex@example.com", Subject: "Héllo", Body: ""}, true},
+		{Message{To: "alex@example.com\r\nBcc: sam@example.com", Subject: "Hello"}, false},
+		{Message{To: "alex@example.com", Subject: "Hello\nBcc: sam@example.com"}, false},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		data, err := item.msg.format("No reply <no-reply@example.com>")
+		if (err == nil) != item.ok {
+			t.Errorf("Incorrect error for %q, got %v, expected ok %v", item.msg.To+item.msg.Subject, err, item.ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		text := string(data)
+		for _, header := range []string{"From: No reply <no-reply@example.com>\r\n", "To: " + item.msg.To + "\r\n", "Subject: ", "@example.com>\r\n", "\r\n\r\n"} {
+			if !strings.Contains(text, header) {
+				t.Errorf("Incorrect message for %q, %q is missing from:\n%s", item.msg.Subject, header, text)
+			}
+		}
+		if strings.Contains(strings.ReplaceAll(text, "\r\n", ""), "\n") {
+			t.Errorf("Incorrect line endings for %q, got %q", item.msg.Subject, text)
+		}
+	}
+}
+
+// TestNew checks that the settings select the driver
+func TestNew(t *testing.T) {
+	// Define a table of test cases with the settings and the expected driver
+	table := []struct {
+		cfg    config.Mail
+		driver string
+	}{
+		{config.Mail{Driver: "smtp", Host: "mail.example.com", Port: 587}, "*mail.SMTP"},
+		{config.Mail{Driver: "smtp"}, ""},
+		{config.Mail{Driver: "file", Dir: "mail"}, "*mail.File"},
+		{config.Mail{Driver: "memory"}, "*mail.Memory"},
+		{config.Mail{Driver: "pigeon"}, ""},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		mailer, err := New(item.cfg)
+		driver := ""
+		switch mailer.(type) {
+		case *SMTP:
+			driver = "*mail.SMTP"
+		case *File:
+			driver = "*mail.File"
+		case *Memory:
+			driver = "*mail.Memory"
+		}
+		if driver != item.driver || (err == nil) != (item.driver != "") {
+			t.Errorf("Incorrect mailer for %+v, got %s %v, expected %s", item.cfg, driver, err, item.driver)
+		}
+	}
+}
+
+// TestMemory checks that the memory mailer keeps the messages in order
+func TestMemory(t *testing.T) {
+	mailer := NewMemory()
+	for _, msg := range []Message{{To: "alex@example.com", Subject: "1"}, {To: "sam@example.com", Subject: "2"}, {To: "alex@example.com", Subject: "3"}} {
+		mailer.Send(context.Background(), msg)
+	}
+
+	if len(mailer.Messages()) != 3 {
+		t.Errorf("Incorrect number of messages, got %d, expected %d", len(mailer.Messages()), 3)
+	}
+	if msg, ok := mailer.Last("alex@example.com"); !ok || msg.Subject != "3" {
+		t.Errorf("Incorrect last message, got %+v, expected subject 3", msg)
+	}
+	if _, ok := mailer.Last("kim@example.com"); ok {
+		t.Errorf("Incorrect last message for an address without any")
+	}
+}
+
+// TestFile checks that the file mailer writes each message to a private file
+func TestFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := NewFile(dir, "no-reply@example.com")
+	for i := 0; i < 2; i++ {
+		if err := mailer.Send(context.Background(), Message{To: "alex@example.com", Subject: "Hello", Body: "Token"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Incorrect files, got %v %v, expected 2", files, err)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Incorrect mode of %s, got %v %v, expected %v", files[0], info.Mode().Perm(), err, os.FileMode(0o600))
+	}
+	if data, _ := os.ReadFile(files[0]); !strings.HasSuffix(string(data), "\r\n\r\nToken") {
+		t.Errorf("Incorrect content of %s, got %q", files[0], data)
+	}
+}
+
+// TestSMTP checks the conversation with a fake SMTP server without STARTTLS
+// nor authentication
+func TestSMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Answer every command with a success and record them, with the message
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		lines := []string{}
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+		data := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case data && line == ".":
+				data = false
+				conn.Write([]byte("250 OK\r\n"))
+			case data:
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250 localhost\r\n"))
+			case line == "DATA":
+				data = true
+				conn.Write([]byte("354 Go ahead\r\n"))
+			case line == "QUIT":
+				conn.Write([]byte("221 Bye\r\n"))
+				received <- lines
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+		received <- lines
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := net.LookupPort("tcp", port)
+	mailer := NewSMTP(host, portNumber, "", "", "No reply <no-reply@example.com>")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mailer.Send(ctx, Message{To: "Alex <alex@example.com>", Subject: "Hello", Body: "Token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The envelope only has the addresses, the message keeps the display names
+	conversation := strings.Join(<-received, "\n")
+	for _, line := range []string{"MAIL FROM:<no-reply@example.com>", "RCPT TO:<alex@example.com>", "To: Alex <alex@example.com>", "Token", "QUIT"} {
+		if !strings.Contains(conversation, line) {
+			t.Errorf("Incorrect conversation, %q is missing from:\n%s", line, conversation)
+		}
+	}
+}
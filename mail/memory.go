@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps the emails in memory instead of sending them, so tests can
+// check what was sent without a mail server
+type Memory struct {
+	mu       sync.Mutex // Guards messages
+	messages []Message  // Messages sent so far, oldest first
+}
+
+// NewMemory returns a Mailer without any message
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Send records the message
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far, oldest first
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message{}, m.messages...)
+}
+
+// Last returns the last message sent to the given address, and false when
+// there is none
+func (m *Memory) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+
+	return Message{}, false
+}
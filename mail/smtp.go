@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTP sends the emails through an SMTP server. The connection is upgraded
+// with STARTTLS when the server offers it, and authenticated when a username
+// is set.
+type SMTP struct {
+	host string    // Host name of the server, checked against its certificate
+	addr string    // Host and port of the server
+	from string    // Sender of the emails, e.g. "No reply <no-reply@example.com>"
+	auth smtp.Auth // Credentials of the sender, nil to send anonymously
+}
+
+// NewSMTP returns a Mailer sending the emails from the given address through
+// the SMTP server at host and port
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	mailer := &SMTP{host: host, addr: net.JoinHostPort(host, strconv.Itoa(port)), from: from}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return mailer
+}
+
+// Send delivers the message to the SMTP server, giving up at the deadline of ctx
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	data, err := msg.format(m.from)
+	if err != nil {
+		return err
+	}
+	// The envelope only takes the addresses, without the display names
+	from, err := netmail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	// Connect, the whole conversation must end before the deadline of ctx
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	// Encrypt the connection before sending the credentials and the message
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	// Send the envelope, then the message itself
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
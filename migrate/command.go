@@ -0,0 +1,174 @@
+package migrate
+
+import (
+	"config"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Usage describes the arguments of Command
+const Usage = `usage: migrate up | down [N] | status | create NAME
+  up           apply every pending migration
+  down [N]     revert the last N applied migrations (default 1)
+  status       list the migrations and whether they are applied
+  create NAME  add an empty pair of migration files`
+
+// Create adds an empty up and down file for a new migration to dir, numbered
+// after the highest version already there, and returns their paths
+func Create(dir, name string) (string, string, error) {
+	name = slug(name)
+	if name == "" {
+		return "", "", errors.New("migrate: the migration needs a name, e.g. add_user_role")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	up, down := fileNames(Migration{Version: next(migrations), Name: name})
+	up, down = filepath.Join(dir, up), filepath.Join(dir, down)
+
+	// Never overwrite a migration, even one with the same version
+	for file, content := range map[string]string{
+		up:   "-- Statements applying the migration, each one ending with a semicolon\n",
+		down: "-- Statements reverting the up migration, in reverse order\n",
+	} {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", fmt.Errorf("migrate: %w", err)
+		}
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	return up, down, nil
+}
+
+// Command runs the "migrate" command of a program with the given arguments,
+// e.g. ["up"] or ["create", "add_user_role"]. The migrations are read from
+// fsys, usually embedded in the program, and created in dir, the directory
+// holding them in the source tree, and recorded in the given table of the
+// program. It connects to the database described by cfg only when needed and
+// reports what it did to out.
+func Command(ctx context.Context, cfg config.Database, fsys fs.FS, dir, table string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	// Creating files does not need the database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(Usage)
+		}
+		up, down, err := Create(dir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Created", up)
+		fmt.Fprintln(out, "Created", down)
+		return nil
+	}
+
+	// Check the arguments before connecting
+	steps := 1
+	switch args[0] {
+	case "up", "status":
+		if len(args) != 1 {
+			return errors.New(Usage)
+		}
+	case "down":
+		if len(args) > 2 {
+			return errors.New(Usage)
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("migrate: invalid number of migrations %q", args[1])
+			}
+			steps = n
+		}
+	default:
+		return fmt.Errorf("migrate: unknown command %q\n%s", args[0], Usage)
+	}
+
+	db, err := open(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := New(db, fsys, table)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintln(out, "Applied", m)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "Nothing to apply, the schema is up to date")
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintln(out, "Reverted", m)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(out, "Nothing to revert")
+		}
+		return err
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MIGRATION\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Migration, state, s.AppliedAt)
+		}
+		return w.Flush()
+	}
+}
+
+// open connects to the MySQL database described by cfg
+func open(ctx context.Context, cfg config.Database) (*sql.DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", cfg.DataSourceName())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
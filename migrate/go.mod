@@ -0,0 +1,16 @@
+module migrate
+
+go 1.23.2
+
+require (
+	config v0.0.0
+	github.com/go-sql-driver/mysql v1.8.1
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace config => ../config
@@ -0,0 +1,328 @@
+// Package migrate applies versioned SQL migrations to a MySQL database.
+//
+// Migrations are pairs of files named after their version and a short name:
+//
+//	0001_create_users.up.sql    applied by Up
+//	0001_create_users.down.sql  reverts it, applied by Down
+//
+// The versions already applied are recorded in a table named by each program,
+// e.g. schema_migrations_contact, so programs sharing a database keep their
+// own history. Up and Down hold a MySQL named lock (GET_LOCK), named after the
+// table, while they run, so two instances starting together cannot migrate
+// the same database at once.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableSchema creates the table recording the applied migrations, named by the Migrator
+const tableSchema = `CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`
+
+// tableName matches the names accepted for the table recording the applied migrations
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// ErrLocked is returned when another instance holds the migration lock for longer than LockTimeout
+var ErrLocked = errors.New("migrate: another instance is migrating the database")
+
+// fileName matches the name of a migration file, e.g. "0001_create_users.up.sql"
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a change of the schema together with the statements to revert it
+type Migration struct {
+	Version int64  // Number ordering the migrations, taken from the file name
+	Name    string // Short description, e.g. "create_users"
+	Up      string // Statements applying the migration
+	Down    string // Statements reverting the migration
+}
+
+// String returns the version and the name of the migration, e.g. "0001_create_users"
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status tells whether a migration has been applied
+type Status struct {
+	Migration
+	Applied   bool   // Whether the version is recorded in the table of the migrations
+	AppliedAt string // When it was applied, empty if it is pending
+}
+
+// Load reads the migrations found at the root of fsys, sorted by version.
+// Every version needs both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+
+		// Both files of a version must have the same name
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: %s needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies a set of migrations to a database
+type Migrator struct {
+	db          *sql.DB
+	table       string // Table recording the applied migrations
+	migrations  []Migration
+	LockTimeout time.Duration // How long Up and Down wait for another instance to finish
+}
+
+// New returns a Migrator applying the migrations found in fsys to db and
+// recording them in the given table, e.g. "schema_migrations_contact"
+func New(db *sql.DB, fsys fs.FS, table string) (*Migrator, error) {
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("migrate: invalid table name %q", table)
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, table: table, migrations: migrations, LockTimeout: 30 * time.Second}, nil
+}
+
+// Up applies every pending migration in order and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := []Migration{}
+	err := m.locked(ctx, func(conn *sql.Conn, done map[int64]string) error {
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := run(ctx, conn, migration.Up); err != nil {
+				return fmt.Errorf("migrate: %s: %w", migration, err)
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT INTO "+m.table+" (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last n applied migrations, newest first, and returns the ones reverted
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	reverted := []Migration{}
+	err := m.locked(ctx, func(conn *sql.Conn, done map[int64]string) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < n; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := run(ctx, conn, migration.Down); err != nil {
+				return fmt.Errorf("migrate: %s: %w", migration, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM "+m.table+" WHERE version = ?", migration.Version); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status returns every migration with whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, migration := range m.migrations {
+		appliedAt, ok := done[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the migration lock. fn receives
+// the versions already applied, mapped to the time they were applied.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, done map[int64]string) error) error {
+	// The lock belongs to the session, so everything runs on the same connection
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The lock is named after the database and the table, so other databases of
+	// the server and other programs using this one are not blocked.
+	// GET_LOCK returns 1 when the lock is taken and 0 when the timeout passes.
+	var got sql.NullInt64
+	lock := "CONCAT(DATABASE(), '." + m.table + "')"
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK("+lock+", ?)", int(m.LockTimeout.Seconds())).Scan(&got); err != nil {
+		return err
+	}
+	if got.Int64 != 1 {
+		return ErrLocked
+	}
+	defer func() {
+		conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK("+lock+")").Scan(&got)
+	}()
+
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, done)
+}
+
+// applied creates the table of the migrations if needed and returns the
+// versions recorded in it, mapped to the time they were applied. A version
+// recorded with another name means the database was migrated by another
+// program, which is reported as an error instead of mixing both schemas.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]string, error) {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(tableSchema, m.table)); err != nil {
+		return nil, err
+	}
+
+	names := map[int64]string{}
+	for _, migration := range m.migrations {
+		names[migration.Version] = migration.Name
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM "+m.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]string{}
+	for rows.Next() {
+		var version int64
+		var name string
+		var appliedAt sql.NullString
+		if err := rows.Scan(&version, &name, &appliedAt); err != nil {
+			return nil, err
+		}
+		if expected, ok := names[version]; ok && expected != name {
+			return nil, fmt.Errorf("migrate: version %d was applied as %q but the migration is %q, is the database used by another program?", version, name, expected)
+		}
+		done[version] = appliedAt.String
+	}
+
+	return done, rows.Err()
+}
+
+// run executes the statements of a migration one by one. MySQL commits every
+// CREATE, ALTER or DROP right away, so a failing migration may be half applied.
+func run(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, statement := range Statements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Statements splits a script into its statements. Statements end with a
+// semicolon at the end of a line, lines starting with "--" are comments.
+func Statements(script string) []string {
+	statements := []string{}
+	current := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(script, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = nil
+		}
+	}
+
+	// The last statement may lack its semicolon
+	if len(current) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+
+	return statements
+}
+
+// next returns the version following the highest one of migrations
+func next(migrations []Migration) int64 {
+	if len(migrations) == 0 {
+		return 1
+	}
+
+	return migrations[len(migrations)-1].Version + 1
+}
+
+// slug turns a description into the name of a migration, e.g. "Add user role" into "add_user_role"
+func slug(name string) string {
+	words := regexp.MustCompile(`[^a-z0-9]+`).Split(strings.ToLower(name), -1)
+
+	return strings.Trim(strings.Join(words, "_"), "_")
+}
+
+// fileNames returns the up and down file names of a migration
+func fileNames(m Migration) (string, string) {
+	return m.String() + ".up.sql", m.String() + ".down.sql"
+}
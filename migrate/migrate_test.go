@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestLoad checks that migrations are paired and sorted by version.
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_role.up.sql":       {Data: []byte("ALTER TABLE users ADD role VARCHAR(10);")},
+		"0002_add_role.down.sql":     {Data: []byte("ALTER TABLE users DROP role;")},
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, m := range migrations {
+		got = append(got, m.String())
+	}
+	expected := []string{"0001_create_users", "0002_add_role"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Incorrect migrations, got %v, expected %v", got, expected)
+	}
+	if migrations[0].Down != "DROP TABLE users;" {
+		t.Errorf("Incorrect down statements, got %q", migrations[0].Down)
+	}
+}
+
+// TestLoadErrors checks that incomplete or conflicting migrations are rejected.
+func TestLoadErrors(t *testing.T) {
+	// Define a table of test cases with the files and the expected error.
+	table := []struct {
+		fsys fstest.MapFS
+		want string
+	}{
+		{fstest.MapFS{"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);")}}, "needs both an up and a down file"},
+		{fstest.MapFS{
+			"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+			"0001_create_posts.down.sql": {Data: []byte("DROP TABLE posts;")},
+		}, "version 1 is used by"},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		if _, err := Load(item.fsys); err == nil || !strings.Contains(err.Error(), item.want) {
+			t.Errorf("Incorrect error, got %v, expected it to contain %q", err, item.want)
+		}
+	}
+}
+
+// TestNewTable checks that only plain names are accepted for the table of the migrations.
+func TestNewTable(t *testing.T) {
+	// Define a table of test cases with the table name and whether it is accepted.
+	table := []struct {
+		name  string
+		valid bool
+	}{
+		{"schema_migrations_contact", true},
+		{"_migrations", true},
+		{"", false},
+		{"1migrations", false},
+		{"schema_migrations; DROP TABLE users", false},
+		{"schema`migrations", false},
+		{"schema_migrations_" + strings.Repeat("x", 60), false},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		_, err := New(nil, fstest.MapFS{}, item.name)
+		if (err == nil) != item.valid {
+			t.Errorf("Incorrect error for %q, got %v, expected valid %v", item.name, err, item.valid)
+		}
+	}
+}
+
+// TestStatements checks how scripts are split into statements.
+func TestStatements(t *testing.T) {
+	script := "-- Users of the API\r\nCREATE TABLE users (\r\n\tid INT,\r\n\tname TEXT);\r\n\r\nCREATE INDEX users_name ON users (name)"
+
+	got := Statements(script)
+	expected := []string{"CREATE TABLE users (\n\tid INT,\n\tname TEXT)", "CREATE INDEX users_name ON users (name)"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Incorrect statements, got %q, expected %q", got, expected)
+	}
+}
+
+// TestCreate checks that new migrations follow the highest version and never overwrite files.
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	up, down, err := Create(dir, "Create users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != "0001_create_users.up.sql" || filepath.Base(down) != "0001_create_users.down.sql" {
+		t.Errorf("Incorrect files, got %s and %s", up, down)
+	}
+
+	up, _, err = Create(dir, "add-user-role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != "0002_add_user_role.up.sql" {
+		t.Errorf("Incorrect file, got %s, expected %s", filepath.Base(up), "0002_add_user_role.up.sql")
+	}
+
+	// A migration without a name is refused
+	if _, _, err := Create(dir, "--"); err == nil {
+		t.Error("Expected an error for an empty name")
+	}
+
+	// Every created file is a valid, empty migration
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || len(Statements(migrations[1].Up)) != 0 {
+		t.Errorf("Incorrect migrations created, got %+v", migrations)
+	}
+}
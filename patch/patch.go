@@ -0,0 +1,371 @@
+// Package patch applies the two kinds of patch documents a PATCH request can
+// carry to a JSON document: JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902).
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Content types of the patch documents, see Apply
+const (
+	MergePatchType = "application/merge-patch+json" // RFC 7396, a partial document
+	JSONPatchType  = "application/json-patch+json"  // RFC 6902, a list of operations
+)
+
+// Errors returned when a patch cannot be applied. The errors returned by
+// Apply wrap one of them, check the kind with errors.Is.
+var (
+	ErrUnsupportedType = errors.New("patch: unsupported content type") // Neither a merge patch nor a JSON patch
+	ErrInvalid         = errors.New("patch: invalid patch")            // The patch is not valid JSON or has an unknown operation
+	ErrCannotApply     = errors.New("patch: cannot apply")             // An operation names a location that does not exist
+	ErrTestFailed      = errors.New("patch: test failed")              // A "test" operation did not match the document
+)
+
+// Apply applies the patch to the JSON document and returns the patched document.
+// The kind of patch is chosen by its content type: "application/json" is read
+// as a merge patch, since that is what most clients send.
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case MergePatchType, "application/json":
+		return Merge(doc, patch)
+	case JSONPatchType:
+		return Operations(doc, patch)
+	default:
+		return nil, fmt.Errorf("%w %q, use %s or %s", ErrUnsupportedType, mediaType, MergePatchType, JSONPatchType)
+	}
+}
+
+// Merge applies a JSON Merge Patch (RFC 7396): the members of the patch replace
+// those of the document, objects are merged recursively and null removes a member.
+func Merge(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return json.Marshal(merge(target, changes))
+}
+
+// merge returns target with the changes of the patch
+func merge(target, patch interface{}) interface{} {
+	// Anything but an object replaces the target as a whole
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(object, name)
+		} else {
+			object[name] = merge(object[name], value)
+		}
+	}
+
+	return object
+}
+
+// Operation is one step of a JSON Patch
+type Operation struct {
+	Op    string          `json:"op"`              // add, remove, replace, move, copy or test
+	Path  string          `json:"path"`            // JSON Pointer (RFC 6901) to the target location
+	From  string          `json:"from,omitempty"`  // Source location of move and copy
+	Value json.RawMessage `json:"value,omitempty"` // Value of add, replace and test
+}
+
+// Operations applies a JSON Patch (RFC 6902), a list of operations run in
+// order. Either every operation is applied or the document is left as is.
+func Operations(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	operations := []Operation{}
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("%w: must be an array of operations", ErrInvalid)
+	}
+
+	for i, operation := range operations {
+		if target, err = operation.apply(target); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// apply runs the operation on the document and returns the updated document
+func (operation Operation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		if doc, _, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		// A value cannot be moved into one of its own children
+		if operation.Path != operation.From && strings.HasPrefix(operation.Path, operation.From+"/") {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", ErrCannotApply, operation.From)
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, clone(value))
+	case "test":
+		value, err := operation.value()
+		if err != nil {
+			return nil, err
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalid, operation.Op)
+	}
+}
+
+// value decodes the value of the operation, which is required for add, replace and test
+func (operation Operation) value() (interface{}, error) {
+	if operation.Value == nil {
+		return nil, fmt.Errorf("%w: missing value", ErrInvalid)
+	}
+
+	return decode(operation.Value)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its reference tokens,
+// e.g. "/a~1b/0" into ["a/b", "0"]. The empty pointer is the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalid, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// get returns the value at the path
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		child, err := child(doc, token)
+		if err != nil {
+			return nil, err
+		}
+		doc = child
+	}
+
+	return doc, nil
+}
+
+// add inserts the value at the path: it sets a member of an object or inserts
+// an element in an array ("-" appends). The empty path replaces the document.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch parent := parent.(type) {
+		case map[string]interface{}:
+			parent[token] = value
+			return parent, nil
+		case []interface{}:
+			if token == "-" {
+				return append(parent, value), nil
+			}
+			i, err := index(token, len(parent)+1)
+			if err != nil {
+				return nil, err
+			}
+			parent = append(parent, nil)
+			copy(parent[i+1:], parent[i:])
+			parent[i] = value
+			return parent, nil
+		default:
+			return nil, fmt.Errorf("%w: %q is not in an object or an array", ErrCannotApply, token)
+		}
+	})
+}
+
+// remove deletes the value at the path and returns it with the updated document
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrCannotApply)
+	}
+
+	var removed interface{}
+	doc, err := update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		value, err := child(parent, token)
+		if err != nil {
+			return nil, err
+		}
+		removed = value
+
+		switch parent := parent.(type) {
+		case map[string]interface{}:
+			delete(parent, token)
+			return parent, nil
+		default:
+			elements := parent.([]interface{})
+			i, _ := index(token, len(elements))
+			return append(elements[:i], elements[i+1:]...), nil
+		}
+	})
+
+	return doc, removed, err
+}
+
+// update walks the path and calls change with the container of its last token,
+// storing the container returned by change back in its parent. Arrays have to
+// be stored back, since inserting or removing elements can reallocate them.
+func update(doc interface{}, path []string, change func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+
+	next, err := child(doc, path[0])
+	if err != nil {
+		return nil, err
+	}
+	next, err = update(next, path[1:], change)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := doc.(type) {
+	case map[string]interface{}:
+		parent[path[0]] = next
+	case []interface{}:
+		i, _ := index(path[0], len(parent))
+		parent[i] = next
+	}
+
+	return doc, nil
+}
+
+// child returns the member or element of the container named by the token
+func child(doc interface{}, token string) (interface{}, error) {
+	switch parent := doc.(type) {
+	case map[string]interface{}:
+		value, ok := parent[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q does not exist", ErrCannotApply, token)
+		}
+		return value, nil
+	case []interface{}:
+		i, err := index(token, len(parent))
+		if err != nil {
+			return nil, err
+		}
+		return parent[i], nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not in an object or an array", ErrCannotApply, token)
+	}
+}
+
+// index parses an array index, which must be lower than size and cannot have leading zeros
+func index(token string, size int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrCannotApply, token)
+	}
+	if i >= size {
+		return 0, fmt.Errorf("%w: index %d is out of range", ErrCannotApply, i)
+	}
+
+	return i, nil
+}
+
+// clone returns a deep copy of a decoded value, so copies do not share their objects and arrays
+func clone(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for name, member := range value {
+			copied[name] = clone(member)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, element := range value {
+			copied[i] = clone(element)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// decode parses a JSON value into maps, slices, strings, float64, bool and nil
+func decode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,119 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// equalJSON reports whether two JSON documents hold the same value, whatever the order of their members
+func equalJSON(t *testing.T, a, b []byte) bool {
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+
+	return reflect.DeepEqual(x, y)
+}
+
+// TestMerge checks the examples of RFC 7396, appendix A.
+func TestMerge(t *testing.T) {
+	// Define a table of test cases with a document, a patch and the expected result.
+	table := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		got, err := Merge([]byte(item.doc), []byte(item.patch))
+		if err != nil {
+			t.Errorf("Unexpected error merging %s into %s: %v", item.patch, item.doc, err)
+			continue
+		}
+		if !equalJSON(t, got, []byte(item.want)) {
+			t.Errorf("Incorrect result merging %s into %s, got %s, expected %s", item.patch, item.doc, got, item.want)
+		}
+	}
+}
+
+// TestOperations checks the examples of RFC 6902, appendix A.
+func TestOperations(t *testing.T) {
+	// Define a table of test cases with a document, a patch and the expected result or error.
+	table := []struct {
+		doc, patch, want string
+		err              error
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, ErrCannotApply},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, nil},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":"bar","baz":"bar"}`, nil},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`, ``, ErrCannotApply},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, ``, ErrCannotApply},
+		{`{"foo":"bar"}`, `[{"op":"jump","path":"/foo"}]`, ``, ErrInvalid},
+		{`{"foo":"bar"}`, `{"op":"add"}`, ``, ErrInvalid},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		got, err := Operations([]byte(item.doc), []byte(item.patch))
+		if item.err != nil {
+			if !errors.Is(err, item.err) {
+				t.Errorf("Incorrect error applying %s to %s, got %v, expected %v", item.patch, item.doc, err, item.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error applying %s to %s: %v", item.patch, item.doc, err)
+			continue
+		}
+		if !equalJSON(t, got, []byte(item.want)) {
+			t.Errorf("Incorrect result applying %s to %s, got %s, expected %s", item.patch, item.doc, got, item.want)
+		}
+	}
+}
+
+// TestApply checks that the kind of patch is chosen by the content type.
+func TestApply(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+
+	if got, err := Apply("application/merge-patch+json; charset=utf-8", doc, []byte(`{"a":2}`)); err != nil || string(got) != `{"a":2}` {
+		t.Errorf("Incorrect merge patch, got %s %v", got, err)
+	}
+	if got, err := Apply(JSONPatchType, doc, []byte(`[{"op":"remove","path":"/a"}]`)); err != nil || string(got) != `{}` {
+		t.Errorf("Incorrect JSON patch, got %s %v", got, err)
+	}
+	if _, err := Apply("text/plain", doc, []byte(`{}`)); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Incorrect error for text/plain, got %v, expected %v", err, ErrUnsupportedType)
+	}
+}
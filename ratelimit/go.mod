@@ -0,0 +1,22 @@
+module ratelimit
+
+go 1.23.2
+
+require (
+	apperr v0.0.0
+	auth v0.0.0
+	logging v0.0.0
+)
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
+	github.com/gorilla/mux v1.8.1 // indirect
+	validation v0.0.0 // indirect
+)
+
+replace (
+	apperr => ../apperr
+	auth => ../auth
+	logging => ../logging
+	validation => ../validation
+)
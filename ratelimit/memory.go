@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets that are full again are dropped,
+// a full bucket behaves like a missing one
+const sweepInterval = time.Minute
+
+// bucket holds the tokens of a client on a route
+type bucket struct {
+	tokens  float64   // Tokens left, refilled lazily
+	updated time.Time // When tokens was last computed
+	limit   Limit     // Limit of the route, to know when the bucket is full
+}
+
+// refill adds the tokens earned since the last update, up to the size of the bucket
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.Requests), b.tokens+now.Sub(b.updated).Seconds()*b.limit.rate())
+	b.updated = now
+}
+
+// MemoryStore implements Store with buckets kept in memory. Each instance of
+// the server then has its own limits.
+// It is safe for concurrent use.
+type MemoryStore struct {
+	mu        sync.Mutex         // Guards the fields below
+	buckets   map[string]*bucket // Bucket of each route and client
+	lastSweep time.Time          // When the full buckets were last dropped
+	now       func() time.Time   // Current time, replaced by the tests
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Take takes a token from the bucket with the given key
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+
+	result := Result{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = limit.timeFor(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = limit.timeFor(float64(limit.Requests) - b.tokens)
+
+	return result, nil
+}
+
+// sweep drops the buckets that are full again, so clients that stopped
+// sending requests do not keep memory forever
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Requests) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package ratelimit limits how often a client can call a route, with a token
+// bucket per route and client. The buckets are kept by a Store: MemoryStore
+// keeps them in the process, a store shared by several instances of the
+// server, e.g. on Redis, only has to implement the same interface.
+package ratelimit
+
+import (
+	"apperr"
+	"auth"
+	"context"
+	"logging"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers describing the limit of the route to the client
+const (
+	LimitHeader     = "X-RateLimit-Limit"     // Size of the bucket
+	RemainingHeader = "X-RateLimit-Remaining" // Requests left in the bucket
+	ResetHeader     = "X-RateLimit-Reset"     // Seconds until the bucket is full again
+)
+
+// Limit allows a burst of Requests requests, the bucket is refilled
+// continuously and is full again after Per
+type Limit struct {
+	Requests int           // Size of the bucket
+	Per      time.Duration // Time to refill the whole bucket
+}
+
+// rate returns the number of tokens added to the bucket per second
+func (limit Limit) rate() float64 {
+	return float64(limit.Requests) / limit.Per.Seconds()
+}
+
+// timeFor returns the time needed to earn the given number of tokens
+func (limit Limit) timeFor(tokens float64) time.Duration {
+	return time.Duration(tokens / limit.rate() * float64(time.Second))
+}
+
+// Result is the state of a bucket after taking a token from it
+type Result struct {
+	Allowed    bool          // Whether the bucket had a token for the request
+	Remaining  int           // Tokens left in the bucket
+	RetryAfter time.Duration // Time until the next token, when the request is not allowed
+	Reset      time.Duration // Time until the bucket is full again
+}
+
+// Store keeps the buckets of the clients
+type Store interface {
+	// Take takes a token from the bucket with the given key, creating it
+	// full when it does not exist yet
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// KeyFunc returns the client a request is counted for
+type KeyFunc func(r *http.Request) string
+
+// ByIP counts the requests by IP address of the client. The address is the
+// one of the connection, headers such as X-Forwarded-For are not trusted.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// ByUserOrIP counts the requests by authenticated user, so users behind the
+// same address do not share their limit, or by IP address for anonymous
+// requests. It must run after the authentication middleware.
+func ByUserOrIP(r *http.Request) string {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		return "user:" + strconv.FormatInt(identity.Id, 10)
+	}
+
+	return ByIP(r)
+}
+
+// Limiter creates the middlewares limiting the routes, on top of a Store
+type Limiter struct {
+	store Store
+}
+
+// New returns a Limiter keeping its buckets in the given store
+func New(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Limit returns a middleware allowing each client, as told by key, the given
+// limit on the route with the given name. Requests over the limit get a 429
+// with a Retry-After header, every response tells the state of the bucket in
+// the X-RateLimit-* headers. When the store fails, requests are let through.
+func (l *Limiter) Limit(name string, limit Limit, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			result, err := l.store.Take(r.Context(), name+":"+key(r), limit)
+			if err != nil {
+				// Better serve too many requests than none
+				logging.FromContext(r.Context()).Error("rate limit store failed", "route", name, "err", err)
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			rw.Header().Set(LimitHeader, strconv.Itoa(limit.Requests))
+			rw.Header().Set(RemainingHeader, strconv.Itoa(result.Remaining))
+			rw.Header().Set(ResetHeader, seconds(result.Reset))
+			if !result.Allowed {
+				rw.Header().Set("Retry-After", seconds(result.RetryAfter))
+				apperr.Writer(rw, r, apperr.TooManyRequests("Too many requests, try again later"))
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// seconds rounds d up to whole seconds, at least 1 so clients never retry right away
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
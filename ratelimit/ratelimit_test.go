@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMemoryStore checks that a bucket allows a burst, then refills over time.
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 3, Per: 3 * time.Second} // One token per second
+
+	// Define a table of test cases with the time passed before a request and the expected result
+	table := []struct {
+		wait       time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{0, true, 2, 0},
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		{0, false, 0, time.Second},
+		{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{500 * time.Millisecond, true, 0, 0},
+		{10 * time.Second, true, 2, 0}, // The bucket never holds more than its size
+	}
+
+	// Loop through each test case
+	for i, item := range table {
+		now = now.Add(item.wait)
+		result, err := store.Take(context.Background(), "login:ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != item.allowed || result.Remaining != item.remaining || result.RetryAfter != item.retryAfter {
+			t.Errorf("Incorrect result of request %d, got %+v, expected allowed %v, remaining %d, retry after %v", i+1, result, item.allowed, item.remaining, item.retryAfter)
+		}
+	}
+
+	// Buckets that are full again are dropped
+	now = now.Add(time.Hour)
+	store.Take(context.Background(), "login:ip:192.0.2.2", limit)
+	if _, ok := store.buckets["login:ip:192.0.2.1"]; ok || len(store.buckets) != 1 {
+		t.Errorf("Incorrect buckets after the sweep, got %d", len(store.buckets))
+	}
+}
+
+// TestLimit checks the headers of the responses and that each route has its own buckets.
+func TestLimit(t *testing.T) {
+	limiter := New(NewMemoryStore())
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})
+	login := limiter.Limit("login", Limit{Requests: 2, Per: time.Minute}, ByIP)(handler)
+	refresh := limiter.Limit("refresh", Limit{Requests: 2, Per: time.Minute}, ByIP)(handler)
+
+	// Define a table of test cases with the route called and the expected headers
+	table := []struct {
+		handler                      http.Handler
+		status                       int
+		remaining, reset, retryAfter string
+	}{
+		{login, http.StatusOK, "1", "30", ""},
+		{login, http.StatusOK, "0", "60", ""},
+		{login, http.StatusTooManyRequests, "0", "60", "30"},
+		{refresh, http.StatusOK, "1", "30", ""},
+	}
+
+	// Loop through each test case
+	for i, item := range table {
+		rw := httptest.NewRecorder()
+		item.handler.ServeHTTP(rw, httptest.NewRequest("POST", "/api/login", nil))
+		header := rw.Header()
+		if rw.Code != item.status || header.Get(LimitHeader) != "2" || header.Get(RemainingHeader) != item.remaining ||
+			header.Get(ResetHeader) != item.reset || header.Get("Retry-After") != item.retryAfter {
+			t.Errorf("Incorrect response %d, got %d %v", i+1, rw.Code, header)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"apperr"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// LimitBody returns a middleware reading the whole request body before the
+// handler, so a body of more than limit bytes gets a 413 right away. Use it
+// on routes decoding small bodies, e.g. JSON documents.
+func LimitBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				apperr.Writer(rw, r, tooLarge(limit))
+				return
+			}
+
+			// Bodies sent in chunks have no length, read them up to the limit
+			body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, limit))
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				apperr.Writer(rw, r, tooLarge(limit))
+				return
+			}
+			if err != nil {
+				apperr.Writer(rw, r, apperr.BadRequest("The request body cannot be read"))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// LimitStream returns a middleware limiting the request body to limit bytes
+// while letting the handler stream it, e.g. for imports. A body announcing a
+// larger Content-Length gets a 413 right away, otherwise reading past the
+// limit fails with an *http.MaxBytesError and the connection is closed.
+func LimitStream(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				apperr.Writer(rw, r, tooLarge(limit))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(rw, r.Body, limit)
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// tooLarge returns the error telling the client the size allowed
+func tooLarge(limit int64) error {
+	return apperr.TooLarge(fmt.Sprintf("The request body must not be larger than %d bytes", limit))
+}
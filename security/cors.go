@@ -0,0 +1,124 @@
+package security
+
+import (
+	"config"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// exposedHeaders are the headers of the responses the scripts of other
+// origins can read, besides the ones browsers always expose
+var exposedHeaders = []string{"ETag", "Accept-Patch", "Content-Disposition", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Request-ID"}
+
+// CORSConfig holds the settings of Cross-Origin Resource Sharing
+type CORSConfig struct {
+	AllowedOrigins   []string // Origins allowed to call the server, "*" for any, none disables CORS
+	AllowedMethods   []string // Methods the other origins can use
+	AllowedHeaders   []string // Request headers the other origins can send
+	AllowCredentials bool     // Whether browsers send the cookies and the Authorization header of the user
+	MaxAge           int      // Seconds browsers can cache the answer to a preflight request
+}
+
+// CORSConfigFrom returns the CORSConfig matching the settings loaded by the config package
+func CORSConfigFrom(cfg config.CORS) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   split(cfg.AllowedOrigins),
+		AllowedMethods:   split(strings.ToUpper(cfg.AllowedMethods)),
+		AllowedHeaders:   split(cfg.AllowedHeaders),
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
+	}
+}
+
+// split returns the items of a comma separated list, without blanks
+func split(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Validate checks that credentials are not shared with any origin
+func (cfg CORSConfig) Validate() error {
+	if cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*") {
+		return errors.New("security: CORS credentials cannot be allowed for any origin, list the origins")
+	}
+
+	return nil
+}
+
+// allowsOrigin reports whether requests from the given origin are allowed
+func (cfg CORSConfig) allowsOrigin(origin string) bool {
+	return slices.Contains(cfg.AllowedOrigins, "*") || slices.Contains(cfg.AllowedOrigins, origin)
+}
+
+// CORS returns a middleware answering the requests of the allowed origins
+// with the CORS headers. Preflight OPTIONS requests are answered with a 204
+// when the router has a route for the method they ask for, the routes do
+// not need to accept OPTIONS. Requests of other origins get no CORS
+// headers, so browsers do not let their pages read the responses.
+func CORS(cfg CORSConfig, router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || len(cfg.AllowedOrigins) == 0 {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			// The answer depends on the origin, caches must keep one per origin
+			rw.Header().Add("Vary", "Origin")
+			if !cfg.allowsOrigin(origin) {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			header := rw.Header()
+			if slices.Contains(cfg.AllowedOrigins, "*") {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// Answer the preflight request sent before the actual one
+			method := r.Header.Get("Access-Control-Request-Method")
+			if r.Method == http.MethodOptions && method != "" {
+				if !slices.Contains(cfg.AllowedMethods, method) || !routed(router, r, method) {
+					next.ServeHTTP(rw, r)
+					return
+				}
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				header.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+				header.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+				header.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			header.Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// routed reports whether the router has a route for the path of r with the given method
+func routed(router *mux.Router, r *http.Request, method string) bool {
+	actual := r.Clone(r.Context())
+	actual.Method = method
+
+	var match mux.RouteMatch
+	return router.Match(actual, &match) && match.MatchErr == nil
+}
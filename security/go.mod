@@ -0,0 +1,23 @@
+module security
+
+go 1.23.2
+
+require (
+	apperr v0.0.0
+	config v0.0.0
+	github.com/gorilla/mux v1.8.1
+)
+
+require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	logging v0.0.0 // indirect
+	validation v0.0.0 // indirect
+)
+
+replace (
+	apperr => ../apperr
+	config => ../config
+	logging => ../logging
+	validation => ../validation
+)
@@ -0,0 +1,125 @@
+package security
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestCORS checks the CORS headers of simple and preflight requests from
+// allowed and other origins.
+func TestCORS(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/user/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {}).Methods("GET", "PUT")
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+	handler := CORS(cfg, router)(router)
+
+	// Define a table of test cases with a request and the expected response
+	table := []struct {
+		method, path, origin, requestMethod string
+		status                              int
+		allowOrigin, allowMethods           string
+	}{
+		{"GET", "/api/user/1", "https://app.example.com", "", http.StatusOK, "https://app.example.com", ""},
+		{"GET", "/api/user/1", "https://evil.example.com", "", http.StatusOK, "", ""},
+		{"GET", "/api/user/1", "", "", http.StatusOK, "", ""},
+		{"OPTIONS", "/api/user/1", "https://app.example.com", "PUT", http.StatusNoContent, "https://app.example.com", "GET, PUT"},
+		{"OPTIONS", "/api/user/1", "https://evil.example.com", "PUT", http.StatusMethodNotAllowed, "", ""},
+		{"OPTIONS", "/api/user/1", "https://app.example.com", "DELETE", http.StatusMethodNotAllowed, "https://app.example.com", ""},
+		{"OPTIONS", "/nowhere", "https://app.example.com", "GET", http.StatusNotFound, "https://app.example.com", ""},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		r := httptest.NewRequest(item.method, item.path, nil)
+		if item.origin != "" {
+			r.Header.Set("Origin", item.origin)
+		}
+		if item.requestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", item.requestMethod)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+
+		name := item.method + " " + item.path + " from " + item.origin
+		if rw.Code != item.status {
+			t.Errorf("Incorrect status for %s, got %d, expected %d", name, rw.Code, item.status)
+		}
+		if got := rw.Header().Get("Access-Control-Allow-Origin"); got != item.allowOrigin {
+			t.Errorf("Incorrect allowed origin for %s, got %q, expected %q", name, got, item.allowOrigin)
+		}
+		if got := rw.Header().Get("Access-Control-Allow-Methods"); got != item.allowMethods {
+			t.Errorf("Incorrect allowed methods for %s, got %q, expected %q", name, got, item.allowMethods)
+		}
+		if item.allowOrigin != "" && rw.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("Incorrect credentials for %s, got %v", name, rw.Header())
+		}
+	}
+
+	// Credentials are never shared with any origin
+	cfg.AllowedOrigins = []string{"*"}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Incorrect validation, credentials are allowed for any origin")
+	}
+}
+
+// TestLimitBody checks that bodies over the limit get a 413, whether they
+// announce their length or are sent in chunks.
+func TestLimitBody(t *testing.T) {
+	read := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	// Define a table of test cases with the middleware, the size of the body and the expected status
+	table := []struct {
+		name    string
+		handler http.Handler
+		size    int
+		chunked bool
+		status  int
+	}{
+		{"small body", LimitBody(10)(read), 10, false, http.StatusOK},
+		{"large body", LimitBody(10)(read), 11, false, http.StatusRequestEntityTooLarge},
+		{"large chunked body", LimitBody(10)(read), 11, true, http.StatusRequestEntityTooLarge},
+		{"small stream", LimitStream(10)(read), 10, true, http.StatusOK},
+		{"large stream", LimitStream(10)(read), 11, false, http.StatusRequestEntityTooLarge},
+		{"large chunked stream", LimitStream(10)(read), 11, true, http.StatusBadRequest}, // The handler fails reading it
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		r := httptest.NewRequest("POST", "/api/user/", strings.NewReader(strings.Repeat("a", item.size)))
+		if item.chunked {
+			r.ContentLength = -1
+		}
+		rw := httptest.NewRecorder()
+		item.handler.ServeHTTP(rw, r)
+		if rw.Code != item.status {
+			t.Errorf("Incorrect status for the %s, got %d, expected %d", item.name, rw.Code, item.status)
+		}
+	}
+}
+
+// TestHeaders checks that the security headers are set on every response.
+func TestHeaders(t *testing.T) {
+	rw := httptest.NewRecorder()
+	Headers(http.NotFoundHandler()).ServeHTTP(rw, httptest.NewRequest("GET", "/nowhere", nil))
+
+	for _, name := range []string{"Strict-Transport-Security", "X-Content-Type-Options", "X-Frame-Options", "Content-Security-Policy", "Referrer-Policy"} {
+		if rw.Header().Get(name) == "" {
+			t.Errorf("Incorrect headers, %s is missing", name)
+		}
+	}
+}
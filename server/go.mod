@@ -0,0 +1,12 @@
+module server
+
+go 1.23.2
+
+require config v0.0.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace config => ../config
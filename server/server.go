@@ -0,0 +1,134 @@
+package server
+
+import (
+	"config"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Config holds the settings of the HTTP server
+type Config struct {
+	Addr            string        // Address to listen on, e.g. ":3000" or "127.0.0.1:8080"
+	ReadTimeout     time.Duration // Maximum time to read a whole request, body included
+	WriteTimeout    time.Duration // Maximum time to write the response
+	IdleTimeout     time.Duration // Maximum time to keep an idle keep-alive connection open
+	ShutdownTimeout time.Duration // Maximum time to wait for in-flight requests on shutdown
+	DrainDelay      time.Duration // Time to keep serving after the signal, so load balancers see the server is not ready
+	CertFile        string        // PEM certificate, the server uses TLS when it is set
+	KeyFile         string        // PEM private key of the certificate
+}
+
+// ConfigFrom returns the Config of a server with the settings loaded by the config package
+func ConfigFrom(cfg config.Server) Config {
+	return Config{
+		Addr:            cfg.Address(),
+		ReadTimeout:     cfg.ReadTimeout,
+		WriteTimeout:    cfg.WriteTimeout,
+		IdleTimeout:     cfg.IdleTimeout,
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		DrainDelay:      cfg.DrainDelay,
+		CertFile:        cfg.CertFile,
+		KeyFile:         cfg.KeyFile,
+	}
+}
+
+// Validate checks that the address can be listened on and that TLS has both files
+func (cfg Config) Validate() error {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return fmt.Errorf("server: invalid address %q: %w", cfg.Addr, err)
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return errors.New("server: TLS needs both a certificate and a key file")
+	}
+
+	return nil
+}
+
+// TLS reports whether the server uses TLS
+func (cfg Config) TLS() bool {
+	return cfg.CertFile != ""
+}
+
+// URL returns the address of the server as a URL, e.g. "http://localhost:3000"
+func (cfg Config) URL() string {
+	scheme := "http"
+	if cfg.TLS() {
+		scheme = "https"
+	}
+
+	host, port, _ := net.SplitHostPort(cfg.Addr)
+	if host == "" {
+		host = "localhost"
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// Run serves handler until the process receives SIGINT or SIGTERM. It then
+// calls drain, e.g. to fail the readiness probe, keeps serving for DrainDelay
+// so the load balancers stop sending requests, stops accepting connections,
+// waits for in-flight requests to finish (at most ShutdownTimeout) and
+// finally calls cleanup, e.g. to close the database.
+func Run(cfg Config, handler http.Handler, drain, cleanup func()) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelError), // e.g. TLS handshake errors
+	}
+
+	// Listen for the signals sent by Ctrl+C, docker stop, kubernetes, ...
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Serve in the background so we can wait for the signal
+	errs := make(chan error, 1)
+	go func() {
+		if cfg.TLS() {
+			errs <- srv.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+		} else {
+			errs <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		// The server could not start, e.g. the port is already in use
+		cleanup()
+		return err
+	case <-ctx.Done():
+		stop() // A second signal kills the process right away
+	}
+
+	// Tell the load balancers to stop sending requests while they are still served
+	drain()
+	if cfg.DrainDelay > 0 {
+		slog.Info("Draining, waiting before closing the listener", "delay", cfg.DrainDelay.String())
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	slog.Info("Shutting down, waiting for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+
+	// Release the resources once no request uses them anymore
+	cleanup()
+	slog.Info("Server stopped")
+
+	return err
+}
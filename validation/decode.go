@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// DecodeJSON decodes a JSON body into value, rejecting fields that value does not declare.
+// Problems with the body are returned as Errors so they can be reported like
+// any other invalid field, the list is empty when the body was decoded.
+func DecodeJSON(body io.Reader, value interface{}) Errors {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(value)
+	if err == nil {
+		return nil
+	}
+
+	errs := Errors{}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		// A field holds a value of the wrong type, e.g. a number instead of a string
+		errs.Add(typeErr.Field, "must be a "+typeErr.Type.String())
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The body contains a field the value does not declare
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		errs.Add(field, "is not allowed")
+	default:
+		// The body is empty or not valid JSON
+		errs.Add("body", "must be a valid JSON object")
+	}
+
+	return errs
+}
@@ -0,0 +1,126 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// emailRegexp is the same expression used by the go-mysql example to validate emails
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// FieldError describes why a single field is invalid
+type FieldError struct {
+	Field  string `json:"field"`  // Name of the field, as it appears in the JSON body
+	Reason string `json:"reason"` // Human readable reason
+}
+
+// Errors is the list of every invalid field of a value
+type Errors []FieldError
+
+// Add appends a new FieldError to the list
+func (errs *Errors) Add(field, reason string) {
+	*errs = append(*errs, FieldError{Field: field, Reason: reason})
+}
+
+// Error joins every reason into a single message, so Errors can be returned as an error
+func (errs Errors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Field+" "+err.Reason)
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// Struct validates the string fields of a struct against the rules of their
+// `validate` tag. Rules are separated by commas:
+//
+//	required   the field cannot be empty
+//	max=N      the field cannot be longer than N characters
+//	min=N      the field cannot be shorter than N characters
+//	email      the field must be an email address
+//	oneof=a b  the field must be one of the space separated values
+//
+// Every rule except required is skipped for empty fields. Fields are reported
+// with the name of their `json` tag.
+func Struct(value interface{}) Errors {
+	errs := Errors{}
+
+	v := reflect.Indirect(reflect.ValueOf(value))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		name := jsonName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			if reason := check(rule, v.Field(i).String()); reason != "" {
+				errs.Add(name, reason)
+				break // Report a single reason per field
+			}
+		}
+	}
+
+	return errs
+}
+
+// check applies a single rule to a value, returning the reason it fails or ""
+func check(rule, value string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	if name == "required" {
+		if strings.TrimSpace(value) == "" {
+			return "is required"
+		}
+		return ""
+	}
+
+	// The other rules only apply to values that were provided
+	if value == "" {
+		return ""
+	}
+
+	switch name {
+	case "max":
+		n, _ := strconv.Atoi(arg)
+		if utf8.RuneCountInString(value) > n {
+			return fmt.Sprintf("must be at most %d characters long", n)
+		}
+	case "min":
+		n, _ := strconv.Atoi(arg)
+		if utf8.RuneCountInString(value) < n {
+			return fmt.Sprintf("must be at least %d characters long", n)
+		}
+	case "email":
+		if !emailRegexp.MatchString(value) {
+			return "must be a valid email address"
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", ")
+	default:
+		panic("validation: unknown rule " + name)
+	}
+
+	return ""
+}
+
+// jsonName returns the name of the field in JSON documents
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+
+	return field.Name
+}
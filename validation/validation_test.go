@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+// account is a small struct used to exercise every rule
+type account struct {
+	Name  string `json:"name" validate:"required,max=5"`
+	Email string `json:"email" validate:"email"`
+	Kind  string `json:"kind" validate:"oneof=a b"`
+	Code  string `validate:"min=3"`
+}
+
+// TestStruct checks that every rule reports the expected field and reason.
+func TestStruct(t *testing.T) {
+	// Define a table of test cases with a value and the errors expected for it.
+	table := []struct {
+		value account
+		want  string
+	}{
+		{account{Name: "ok"}, ""},
+		{account{}, "name is required"},
+		{account{Name: "toolong"}, "name must be at most 5 characters long"},
+		{account{Name: "ok", Email: "nope"}, "email must be a valid email address"},
+		{account{Name: "ok", Email: "user@email.com"}, ""},
+		{account{Name: "ok", Kind: "c"}, "kind must be one of: a, b"},
+		{account{Name: "ok", Code: "ab"}, "Code must be at least 3 characters long"},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		// Validate the value and compare the joined errors with the expected ones
+		if got := Struct(item.value).Error(); got != item.want {
+			t.Errorf("Incorrect errors for %+v, got %q, expected %q", item.value, got, item.want)
+		}
+	}
+}
+
+// TestDecodeJSON checks that problems with the body are reported as field errors.
+func TestDecodeJSON(t *testing.T) {
+	// Define a table of test cases with a body and the errors expected for it.
+	table := []struct {
+		body string
+		want string
+	}{
+		{`{"name":"ok"}`, ""},
+		{`{"name":"ok","admin":true}`, "admin is not allowed"},
+		{`{"name":5}`, "name must be a string"},
+		{`not json`, "body must be a valid JSON object"},
+	}
+
+	// Loop through each test case
+	for _, item := range table {
+		// Decode the body and compare the joined errors with the expected ones
+		value := account{}
+		if got := DecodeJSON(strings.NewReader(item.body), &value).Error(); got != item.want {
+			t.Errorf("Incorrect errors for %s, got %q, expected %q", item.body, got, item.want)
+		}
+	}
+}